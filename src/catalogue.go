@@ -0,0 +1,112 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"lynisreport/lynis"
+	"os"
+)
+
+// Runs the catalogue command which outputs the catalogue of Lynis tests,
+// optionally updated from a Lynis installation, returns exit code
+func runCatalogue(args []string) int {
+	var help bool
+	var lynisDir string
+	var output string
+
+	flags := flag.NewFlagSet("catalogue", flag.ContinueOnError)
+	flags.BoolVarP(&help,
+		"help",
+		"h",
+		false,
+		"Print help menu")
+	flags.StringVar(&lynisDir,
+		"lynis-dir",
+		"",
+		"Update catalogue from test files in Lynis directory eg. /usr/share/lynis")
+	flags.StringVarP(&output,
+		"output",
+		"o",
+		"",
+		"File to write catalogue to. Default is to standard output")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if help {
+		fmt.Fprintln(os.Stderr, "Outputs the catalogue of Lynis test categories and titles as JSON. Tests")
+		fmt.Fprintln(os.Stderr, "registered in the include/tests_* files of a Lynis installation are added")
+		fmt.Fprintln(os.Stderr, "to the built in catalogue with --lynis-dir. The output can be used with the")
+		fmt.Fprintln(os.Stderr, "--catalogue option.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport catalogue [option]")
+		fmt.Fprintln(os.Stderr, "\tlynisreport catalogue --lynis-dir /usr/share/lynis -o catalogue.json")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+
+	catalogue := lynis.DefaultCatalogue()
+	if len(lynisDir) > 0 {
+		tests, err := lynis.ParseLynisTests(lynisDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read Lynis tests. %s\n",
+				err)
+			return ERR_INVALIDOPT
+		}
+		catalogue.Merge(tests)
+	}
+
+	if len(output) < 1 {
+		if err := catalogue.SerializeForJSON(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed writting catalogue. %s\n",
+				err)
+			return ERR_WRITELOG
+		}
+		return 0
+	}
+
+	pending, err := lynis.CreatePendingFile(output, false, 0644)
+	if err == nil {
+		err = catalogue.SerializeForJSON(pending)
+		if err == nil {
+			err = pending.Commit()
+		} else {
+			pending.Abort()
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed writting catalogue. %s\n", err)
+		return ERR_WRITELOG
+	}
+	return 0
+}
+
+// Reads a catalogue file and merges it into the built in catalogue
+func readCatalogue(path string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	catalogue, err := lynis.LoadCatalogue(input)
+	if err != nil {
+		return err
+	}
+	merged := lynis.DefaultCatalogue()
+	merged.Merge(catalogue)
+	lynis.SetCatalogue(merged)
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"lynisreport/lynis"
+	"os"
+	"sort"
+	"time"
+)
+
+// Runs the compliance command which summarizes the controls of a framework
+// for a report, returns exit code
+func runCompliance(args []string) int {
+	var help bool
+	var reportFile string
+	var framework string
+	var mappingFile string
+	var suppressFile string
+	var format string
+
+	flags := flag.NewFlagSet("compliance", flag.ContinueOnError)
+	flags.BoolVarP(&help,
+		"help",
+		"h",
+		false,
+		"Print help menu")
+	flags.StringVarP(&reportFile,
+		"reportfile",
+		"r",
+		"",
+		"Location of Lynis report file. Default is to read from stdin")
+	flags.StringVar(&framework,
+		"framework",
+		"cis",
+		"Framework to summarize controls of")
+	flags.StringVar(&mappingFile,
+		"compliance-map",
+		"",
+		"Location of JSON file mapping tests to controls, replaces built in mapping of the same tests")
+	flags.StringVar(&suppressFile,
+		"suppressions",
+		"",
+		"Location of JSON suppressions file, suppressed findings do not fail controls")
+	flags.StringVarP(&format,
+		"format",
+		"f",
+		"text",
+		"Output format, one of text or json")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if help {
+		fmt.Fprintln(os.Stderr, "Summarizes which controls of a compliance framework passed or failed for a")
+		fmt.Fprintln(os.Stderr, "Lynis report.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport compliance [option] --framework FRAMEWORK")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Frameworks:")
+		fmt.Fprintln(os.Stderr, "")
+		frameworks := lynis.GetComplianceMapping().Frameworks
+		names := make([]string, 0, len(frameworks))
+		for fw := range frameworks {
+			names = append(names, fw)
+		}
+		sort.Strings(names)
+		for _, fw := range names {
+			fmt.Fprintf(os.Stderr, "\t%s\t%s\n", fw, frameworks[fw])
+		}
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "error: unknown format %s\n", format)
+		return ERR_INVALIDOPT
+	}
+
+	if len(mappingFile) > 0 {
+		if err := readComplianceMapping(mappingFile); err != nil {
+			fmt.Fprintf(os.Stderr,
+				"error: failed to read compliance mapping. %s\n", err)
+			return ERR_INVALIDOPT
+		}
+	}
+
+	// read report
+	input := os.Stdin
+	if len(reportFile) > 0 {
+		var err error
+		input, err = os.Open(reportFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return ERR_REPORTFILE
+		}
+		defer input.Close()
+	}
+	report, err := lynis.CreateReport(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed processing report. %s\n", err)
+		return ERR_PROCCESS
+	}
+
+	// mark suppressed findings so they do not fail controls
+	if len(suppressFile) > 0 {
+		suppressions, err := readSuppressions(suppressFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read suppressions. %s\n",
+				err)
+			return ERR_INVALIDOPT
+		}
+		report, _ = suppressions.Apply(report, time.Now(), true)
+	}
+
+	cr, err := lynis.GetComplianceMapping().Evaluate(report, framework)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	switch format {
+	case "text":
+		err = cr.SerializeForText(os.Stdout)
+	case "json":
+		err = cr.SerializeForJSON(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed writting compliance report. %s\n",
+			err)
+		return ERR_WRITELOG
+	}
+	return 0
+}
+
+// Reads a compliance mapping file and merges it into the built in mapping
+func readComplianceMapping(path string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	mapping, err := lynis.LoadComplianceMapping(input)
+	if err != nil {
+		return err
+	}
+	merged := lynis.DefaultComplianceMapping()
+	merged.Merge(mapping)
+	lynis.SetComplianceMapping(merged)
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"lynisreport/lynis"
+	"os"
+)
+
+// Runs the diff command which compares an old and new report, returns exit
+// code
+func runDiff(args []string) int {
+	var help bool
+	var format string
+	var onlyNew bool
+
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.BoolVarP(&help,
+		"help",
+		"h",
+		false,
+		"Print help menu")
+	flags.StringVarP(&format,
+		"format",
+		"f",
+		"text",
+		"Output format, one of text, json or elastic")
+	flags.BoolVar(&onlyNew,
+		"only-new",
+		false,
+		"Only output new findings")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if help {
+		fmt.Fprintln(os.Stderr, "Compares two Lynis reports and classifies each finding as new, resolved or")
+		fmt.Fprintln(os.Stderr, "persisting.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport diff [option] OLD NEW")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+
+	if flags.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "error: diff requires an OLD and NEW report\n")
+		return ERR_INVALIDOPT
+	}
+
+	reports := make([]*lynis.Report, 2)
+	for i, path := range flags.Args() {
+		var err error
+		reports[i], err = lynis.ReadReportFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read report %s. %s\n",
+				path, err)
+			return ERR_REPORTFILE
+		}
+	}
+
+	diff := lynis.Diff(reports[0], reports[1])
+	if onlyNew {
+		diff = diff.Only(lynis.DIFF_NEW)
+	}
+
+	var err error
+	switch format {
+	case "text":
+		err = diff.SerializeForText(os.Stdout)
+	case "json":
+		err = diff.SerializeForJSON(os.Stdout)
+	case "elastic":
+		err = diff.SerializeForElasticSearch(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown format %s\n", format)
+		return ERR_INVALIDOPT
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed writting diff. %s\n", err)
+		return ERR_WRITELOG
+	}
+	return 0
+}
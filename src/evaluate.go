@@ -0,0 +1,178 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"lynisreport/lynis"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Runs the evaluate command which evaluates rules on a report, returns exit
+// code
+func runEvaluate(args []string) int {
+	var help bool
+	var reportFile string
+	var rulesFile string
+	var exprs []string
+	var suppressFile string
+	var format string
+
+	flags := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	flags.BoolVarP(&help,
+		"help",
+		"h",
+		false,
+		"Print help menu")
+	flags.StringVarP(&reportFile,
+		"reportfile",
+		"r",
+		"",
+		"Location of Lynis report file. Default is to read from stdin")
+	flags.StringVar(&rulesFile,
+		"rules",
+		"",
+		"Location of JSON rules file")
+	flags.StringArrayVarP(&exprs,
+		"expr",
+		"e",
+		nil,
+		"Expression to evaluate as a rule, can be repeated")
+	flags.StringVar(&suppressFile,
+		"suppressions",
+		"",
+		"Location of suppressions file which must be JSON, suppressed findings are not counted")
+	flags.StringVarP(&format,
+		"format",
+		"f",
+		"text",
+		"Output format, one of text or json")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if help {
+		fmt.Fprintln(os.Stderr, "Evaluates rules on a Lynis report and outputs whether each rule passed.")
+		fmt.Fprintln(os.Stderr, "Exits with a policy violation if any rule fails or an invalid option if a")
+		fmt.Fprintln(os.Stderr, "rule could not be evaluated.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport evaluate [option] --rules FILE")
+		fmt.Fprintln(os.Stderr, "\tlynisreport evaluate [option] -e EXPR")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Expressions:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tcount(warnings where test =~ \"SSH-*\") == 0 && hardening_index >= 80")
+		fmt.Fprintln(os.Stderr, "\tservice \"telnet\" not in running_service")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "error: unknown format %s\n", format)
+		return ERR_INVALIDOPT
+	}
+
+	// read rules
+	rules := &lynis.Rules{}
+	if len(rulesFile) > 0 {
+		input, err := os.Open(rulesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read rules. %s\n", err)
+			return ERR_INVALIDOPT
+		}
+		rules, err = lynis.LoadRules(input)
+		input.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read rules. %s\n", err)
+			return ERR_INVALIDOPT
+		}
+	}
+	for i, expr := range exprs {
+		rule := &lynis.Rule{Name: "expr-" + strconv.Itoa(i+1), Expr: expr}
+		if err := rule.Compile(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid expression %s. %s\n",
+				expr, err)
+			return ERR_INVALIDOPT
+		}
+		rules.Rules = append(rules.Rules, rule)
+	}
+	if len(rules.Rules) < 1 {
+		fmt.Fprintf(os.Stderr, "error: evaluate requires --rules or --expr\n")
+		return ERR_INVALIDOPT
+	}
+
+	// read report
+	input := os.Stdin
+	if len(reportFile) > 0 {
+		var err error
+		input, err = os.Open(reportFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return ERR_REPORTFILE
+		}
+		defer input.Close()
+	}
+	report, err := lynis.CreateReport(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed processing report. %s\n", err)
+		return ERR_PROCCESS
+	}
+
+	// mark suppressed findings so they are not counted
+	if len(suppressFile) > 0 {
+		suppressions, err := readSuppressions(suppressFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read suppressions. %s\n",
+				err)
+			return ERR_INVALIDOPT
+		}
+		report, _ = suppressions.Apply(report, time.Now(), true)
+	}
+
+	// rules that could not be evaluated are reported before failed rules
+	results := rules.Evaluate(report)
+	code := 0
+	for _, result := range results {
+		if len(result.Error) > 0 {
+			code = ERR_INVALIDOPT
+		} else if !result.Pass && code == 0 {
+			code = ERR_POLICY
+		}
+	}
+
+	switch format {
+	case "text":
+		for _, result := range results {
+			switch {
+			case len(result.Error) > 0:
+				fmt.Printf("ERROR %s: %s\n", result.Name, result.Error)
+			case result.Pass:
+				fmt.Printf("PASS  %s\n", result.Name)
+			default:
+				fmt.Printf("FAIL  %s: %s\n", result.Name, result.Message)
+			}
+		}
+	case "json":
+		data, err := json.Marshal(results)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed writting results. %s\n", err)
+			return ERR_WRITELOG
+		}
+		fmt.Println(string(data))
+	}
+	return code
+}
@@ -0,0 +1,71 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"lynisreport/lynis"
+	"net/http"
+	"os"
+)
+
+// Default address for exporter to listen on
+const (
+	EXPORTER_LISTEN string = ":9732"
+)
+
+// Runs the exporter command which serves metrics about Lynis reports over
+// HTTP, returns exit code
+func runExporter(args []string) int {
+	var help bool
+	var listen string
+	var reports []string
+
+	flags := flag.NewFlagSet("exporter", flag.ContinueOnError)
+	flags.BoolVarP(&help,
+		"help",
+		"h",
+		false,
+		"Print help menu")
+	flags.StringVar(&listen,
+		"listen",
+		EXPORTER_LISTEN,
+		"Address to serve metrics on")
+	flags.StringArrayVarP(&reports,
+		"reportfile",
+		"r",
+		[]string{"/var/log/lynis-report.dat"},
+		"Specify where to find the Lynis report file, can be repeated")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if help {
+		fmt.Fprintln(os.Stderr, "Serves metrics about Lynis reports on /metrics in the OpenMetrics format.")
+		fmt.Fprintln(os.Stderr, "Report files are read again when they change.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport exporter [option]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", lynis.NewExporter(reports...))
+
+	if err := http.ListenAndServe(listen, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_LISTEN
+	}
+	return 0
+}
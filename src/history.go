@@ -0,0 +1,182 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"lynisreport/lynis"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary of a stored report listed by the history command
+type historyRun struct {
+	*lynis.HistoryEntry
+	Warnings       int `json:"warnings"`
+	Suggestions    int `json:"suggestions"`
+	HardeningIndex int `json:"hardening_index"`
+}
+
+// Runs the history command which lists stored reports and when findings of
+// each test were first and last seen, returns exit code
+func runHistory(args []string) int {
+	var help bool
+	var dir string
+	var host string
+	var tests bool
+	var format string
+
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	flags.BoolVarP(&help,
+		"help",
+		"h",
+		false,
+		"Print help menu")
+	flags.StringVarP(&dir,
+		"dir",
+		"d",
+		HISTORY_DIR,
+		"Directory reports are stored in")
+	flags.StringVar(&host,
+		"host",
+		"",
+		"Only list reports of host ID. Default is all hosts")
+	flags.BoolVar(&tests,
+		"tests",
+		false,
+		"List when findings of each test were first and last seen instead of reports")
+	flags.StringVarP(&format,
+		"format",
+		"f",
+		"text",
+		"Output format, one of text or json")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if help {
+		fmt.Fprintln(os.Stderr, "Lists the reports stored in the history of each host, or when findings of")
+		fmt.Fprintln(os.Stderr, "each test were first and last seen.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport history [option]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "error: unknown format %s\n", format)
+		return ERR_INVALIDOPT
+	}
+
+	history := &lynis.History{Dir: dir}
+	hosts := []string{host}
+	if len(host) < 1 {
+		var err error
+		hosts, err = history.Hosts()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read history. %s\n", err)
+			return ERR_HISTORY
+		}
+	}
+
+	// collect output for each host
+	output := make(map[string]interface{})
+	for _, h := range hosts {
+		var err error
+		if tests {
+			output[h], err = history.TestHistory(h)
+		} else {
+			output[h], err = historyRuns(history, h)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read history. %s\n", err)
+			return ERR_HISTORY
+		}
+	}
+
+	if format == "json" {
+		if err := json.NewEncoder(os.Stdout).Encode(output); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return ERR_WRITELOG
+		}
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, h := range hosts {
+		fmt.Fprintf(tw, "%s\n", h)
+		switch rows := output[h].(type) {
+		case []*historyRun:
+			fmt.Fprintln(tw, "  TIME\tWARNINGS\tSUGGESTIONS\tHARDENING INDEX")
+			for _, r := range rows {
+				fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\n",
+					r.Time.Format(time.RFC3339), r.Warnings,
+					r.Suggestions, r.HardeningIndex)
+			}
+		case []*lynis.TestHistory:
+			fmt.Fprintln(tw, "  TEST\tTYPE\tFIRST SEEN\tLAST SEEN\tRUNS\tCURRENT")
+			for _, th := range rows {
+				fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%d\t%t\n", th.Name,
+					th.Type, th.FirstSeen.Format(time.RFC3339),
+					th.LastSeen.Format(time.RFC3339), th.Runs, th.Current)
+			}
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_WRITELOG
+	}
+	return 0
+}
+
+// Loads the summary of every stored report of the host
+func historyRuns(history *lynis.History, host string) ([]*historyRun, error) {
+	entries, err := history.Runs(host)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*historyRun, 0, len(entries))
+	for _, entry := range entries {
+		report, err := history.Load(entry)
+		if err != nil {
+			return nil, err
+		}
+		warnings, suggestions := report.CountUnsuppressed()
+		runs = append(runs, &historyRun{
+			HistoryEntry:   entry,
+			Warnings:       warnings,
+			Suggestions:    suggestions,
+			HardeningIndex: report.HardeningIndex,
+		})
+	}
+	return runs, nil
+}
+
+// Saves the report to the history and removes reports outside of the
+// retention policy
+func saveHistory(report *lynis.Report) error {
+	history, err := lynis.OpenHistory(historyOpt)
+	if err != nil {
+		return err
+	}
+	if _, err := history.Save(report); err != nil {
+		return err
+	}
+
+	_, err = history.Prune(historyKeepOpt,
+		time.Duration(historyMaxDaysOpt)*24*time.Hour, time.Now())
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"lynisreport/lynis"
+	"os"
+)
+
+// Runs the inventory command which outputs the inventory of the hosts of
+// reports, returns exit code
+func runInventory(args []string) int {
+	var help bool
+	var format string
+
+	flags := flag.NewFlagSet("inventory", flag.ContinueOnError)
+	flags.BoolVarP(&help,
+		"help",
+		"h",
+		false,
+		"Print help menu")
+	flags.StringVarP(&format,
+		"format",
+		"f",
+		"json",
+		"Output format, one of json or csv")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if help {
+		fmt.Fprintln(os.Stderr, "Outputs the inventory of the hosts of Lynis reports such as operating system,")
+		fmt.Fprintln(os.Stderr, "kernel, network addresses, running services and installed packages. Reports")
+		fmt.Fprintln(os.Stderr, "are read from files, directories of report files or stdin.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport inventory [option] [FILE|DIR...]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+
+	if format != "json" && format != "csv" {
+		fmt.Fprintf(os.Stderr, "error: unknown format %s\n", format)
+		return ERR_INVALIDOPT
+	}
+
+	var reports []*lynis.Report
+	if flags.NArg() > 0 {
+		var err error
+		reports, err = readReports(flags.Args())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return ERR_REPORTFILE
+		}
+	} else {
+		report, err := lynis.CreateReport(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed processing report. %s\n", err)
+			return ERR_PROCCESS
+		}
+		reports = []*lynis.Report{report}
+	}
+
+	inventories := make([]*lynis.Inventory, 0, len(reports))
+	for _, report := range reports {
+		inventories = append(inventories, report.Inventory())
+	}
+
+	var err error
+	switch format {
+	case "json":
+		for _, inv := range inventories {
+			if err = inv.SerializeForJSON(os.Stdout); err != nil {
+				break
+			}
+		}
+	case "csv":
+		err = lynis.SerializeInventoriesForCSV(os.Stdout, inventories)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed writting inventory. %s\n", err)
+		return ERR_WRITELOG
+	}
+	return 0
+}
@@ -0,0 +1,200 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// URL of the CISOfy description of a test, formatted with the test ID
+	CATALOGUE_REFERENCE_URL string = "https://cisofy.com/lynis/controls/%s/"
+
+	// Regex format string to find tests registered in Lynis test files
+	CATALOGUE_REGISTER_REG string = `^\s*Register\s.*--test-no\s+"?([A-Z]+-[0-9]+)"?.*--description\s+"([^"]*)"`
+
+	// Regex format string of a Lynis test ID
+	CATALOGUE_TEST_ID_REG string = `^[A-Z]+-[0-9]+$`
+)
+
+// Catalogue of Lynis tests that is built into the binary
+//
+//go:embed catalogue.json
+var catalogueJSON []byte
+
+// Catalogue used to add details to tests
+var catalogue = mustLoadCatalogue(catalogueJSON)
+
+var catalogueTestID = regexp.MustCompile(CATALOGUE_TEST_ID_REG)
+
+// Catalogue describes Lynis tests. Categories maps the prefix of test IDs to
+// the name of the category and Tests maps test IDs to their title
+type Catalogue struct {
+	Categories map[string]string `json:"categories"`
+	Tests      map[string]string `json:"tests"`
+}
+
+// TestInfo is the description of a test from the catalogue
+type TestInfo struct {
+	Category  string
+	Title     string
+	Reference string
+}
+
+// Reads a catalogue from JSON
+func LoadCatalogue(input io.Reader) (*Catalogue, error) {
+	c := &Catalogue{}
+	decoder := json.NewDecoder(input)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return nil, err
+	}
+	if c.Categories == nil {
+		c.Categories = make(map[string]string)
+	}
+	if c.Tests == nil {
+		c.Tests = make(map[string]string)
+	}
+	return c, nil
+}
+
+// Reads the built in catalogue, panics since it is part of the binary
+func mustLoadCatalogue(data []byte) *Catalogue {
+	c, err := LoadCatalogue(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Returns a copy of the catalogue that is built into the binary
+func DefaultCatalogue() *Catalogue {
+	return mustLoadCatalogue(catalogueJSON)
+}
+
+// Returns the catalogue used to add details to tests
+func GetCatalogue() *Catalogue {
+	return catalogue
+}
+
+// Sets the catalogue used to add details to tests
+func SetCatalogue(c *Catalogue) {
+	catalogue = c
+}
+
+// Merges the other catalogue into the catalogue, categories and tests in the
+// other catalogue replace the same ones
+func (c *Catalogue) Merge(other *Catalogue) {
+	for prefix, name := range other.Categories {
+		c.Categories[prefix] = name
+	}
+	for id, title := range other.Tests {
+		c.Tests[id] = title
+	}
+}
+
+// Returns the category, title and reference URL of a test. Fields are empty
+// if they are not known
+func (c *Catalogue) Lookup(id string) *TestInfo {
+	info := &TestInfo{
+		Category: c.Categories[TestCategory(id)],
+		Title:    c.Tests[id],
+	}
+	if catalogueTestID.MatchString(id) {
+		info.Reference = fmt.Sprintf(CATALOGUE_REFERENCE_URL, id)
+	}
+	return info
+}
+
+// Serializes the catalogue as indented JSON and writes it to Writer
+func (c *Catalogue) SerializeForJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Creates a catalogue from the test files of a Lynis installation. The
+// directory is the Lynis directory or its include directory. Tests are read
+// from lines registering them, eg.
+//
+//	Register --test-no AUTH-9208 --weight L --network NO --category security --description "Check password file consistency"
+//
+// Categories are named after the file the tests are in unless the built in
+// catalogue already names them
+func ParseLynisTests(dir string) (*Catalogue, error) {
+	include := filepath.Join(dir, "include")
+	if info, err := os.Stat(include); err != nil || !info.IsDir() {
+		include = dir
+	}
+	files, err := filepath.Glob(filepath.Join(include, "tests_*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) < 1 {
+		return nil, errors.New(fmt.Sprintf("no Lynis test files in %s", dir))
+	}
+
+	known := DefaultCatalogue()
+	c := &Catalogue{
+		Categories: make(map[string]string),
+		Tests:      make(map[string]string),
+	}
+	register := regexp.MustCompile(CATALOGUE_REGISTER_REG)
+	for _, file := range files {
+		// tests_file_integrity is named File integrity
+		name := strings.ReplaceAll(
+			strings.TrimPrefix(filepath.Base(file), "tests_"), "_", " ")
+		if len(name) > 0 {
+			name = strings.ToUpper(name[:1]) + name[1:]
+		}
+
+		if err := parseLynisTestFile(file, func(id, title string) {
+			c.Tests[id] = title
+			prefix := TestCategory(id)
+			if known, ok := known.Categories[prefix]; ok {
+				c.Categories[prefix] = known
+			} else if _, ok := c.Categories[prefix]; !ok {
+				c.Categories[prefix] = name
+			}
+		}, register); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Calls add with the ID and description of every test registered in the file
+func parseLynisTestFile(path string, add func(id, title string),
+	register *regexp.Regexp) error {
+
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		if match := register.FindStringSubmatch(scanner.Text()); match != nil {
+			add(match[1], match[2])
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,284 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Status of a control in a compliance report
+const (
+	CONTROL_PASS       string = "pass"       // no findings for tests of control
+	CONTROL_FAIL       string = "fail"       // findings for tests of control
+	CONTROL_NOT_TESTED string = "not_tested" // tests of control not executed
+)
+
+// Mapping of Lynis tests to controls that is built into the binary
+//
+//go:embed compliance.json
+var complianceJSON []byte
+
+// Mapping used to add controls to findings
+var complianceMapping = mustLoadComplianceMapping(complianceJSON)
+
+// ComplianceMapping maps Lynis test IDs to the controls of compliance
+// frameworks. Keys of Tests are test IDs or glob patterns eg. SSH-*, values
+// map the framework to its control identifiers
+type ComplianceMapping struct {
+	Frameworks map[string]string              `json:"frameworks"`
+	Tests      map[string]map[string][]string `json:"tests"`
+}
+
+// ControlResult is whether a report passed a control
+type ControlResult struct {
+	Control string   `json:"control"`
+	Status  string   `json:"status"`
+	Tests   []string `json:"tests"`            // tests mapped to control
+	Failed  []string `json:"failed,omitempty"` // tests with findings
+}
+
+// ComplianceReport summarizes the controls of a framework for a report
+type ComplianceReport struct {
+	Framework string           `json:"framework"`
+	Name      string           `json:"name"`
+	Hostname  string           `json:"hostname"`
+	Passed    int              `json:"passed"`
+	Failed    int              `json:"failed"`
+	NotTested int              `json:"not_tested"`
+	Controls  []*ControlResult `json:"controls"`
+}
+
+// Reads a compliance mapping from JSON
+func LoadComplianceMapping(input io.Reader) (*ComplianceMapping, error) {
+	m := &ComplianceMapping{}
+	decoder := json.NewDecoder(input)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(m); err != nil {
+		return nil, err
+	}
+	if m.Frameworks == nil {
+		m.Frameworks = make(map[string]string)
+	}
+	if m.Tests == nil {
+		m.Tests = make(map[string]map[string][]string)
+	}
+
+	for test := range m.Tests {
+		if _, err := path.Match(test, ""); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid test pattern %s",
+				test))
+		}
+	}
+	return m, nil
+}
+
+// Reads the built in mapping, panics since it is part of the binary
+func mustLoadComplianceMapping(data []byte) *ComplianceMapping {
+	m, err := LoadComplianceMapping(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Returns a copy of the mapping that is built into the binary
+func DefaultComplianceMapping() *ComplianceMapping {
+	return mustLoadComplianceMapping(complianceJSON)
+}
+
+// Returns the mapping used to add controls to findings
+func GetComplianceMapping() *ComplianceMapping {
+	return complianceMapping
+}
+
+// Sets the mapping used to add controls to findings
+func SetComplianceMapping(m *ComplianceMapping) {
+	complianceMapping = m
+}
+
+// Merges the other mapping into the mapping, tests in the other mapping
+// replace the controls of the same tests
+func (m *ComplianceMapping) Merge(other *ComplianceMapping) {
+	for fw, name := range other.Frameworks {
+		m.Frameworks[fw] = name
+	}
+	for test, controls := range other.Tests {
+		m.Tests[test] = controls
+	}
+}
+
+// Returns the keys of the mapping matching the test
+func (m *ComplianceMapping) keys(test string) []string {
+	keys := make([]string, 0)
+	for key := range m.Tests {
+		if match, _ := path.Match(key, test); match {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the controls of every framework the test is mapped to, formatted
+// as FRAMEWORK:CONTROL eg. nist-800-53:AC-17
+func (m *ComplianceMapping) Controls(test string) []string {
+	seen := make(map[string]bool)
+	controls := make([]string, 0)
+	for _, key := range m.keys(test) {
+		for fw, ids := range m.Tests[key] {
+			for _, id := range ids {
+				control := fw + ":" + id
+				if !seen[control] {
+					seen[control] = true
+					controls = append(controls, control)
+				}
+			}
+		}
+	}
+	sort.Slice(controls, func(i, j int) bool {
+		return controlLess(controls[i], controls[j])
+	})
+	return controls
+}
+
+// Summarizes the controls of the framework for the report. A control fails
+// if any test mapped to it has findings that are not suppressed. If the
+// report lists the tests that were executed, controls without any executed
+// test are not tested, otherwise they pass
+func (m *ComplianceMapping) Evaluate(r *Report,
+	framework string) (*ComplianceReport, error) {
+
+	name, ok := m.Frameworks[framework]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown framework %s", framework))
+	}
+	cr := &ComplianceReport{
+		Framework: framework,
+		Name:      name,
+		Hostname:  r.Hostname,
+		Controls:  make([]*ControlResult, 0),
+	}
+
+	// tests mapped to each control of framework
+	tests := make(map[string][]string)
+	for test, frameworks := range m.Tests {
+		for _, id := range frameworks[framework] {
+			tests[id] = append(tests[id], test)
+		}
+	}
+
+	// tests with findings that are not suppressed
+	failed := make([]string, 0)
+	for _, name := range r.TestNames() {
+		for _, f := range r.Tests[name].Findings() {
+			if !f.Element.Suppressed {
+				failed = append(failed, name)
+				break
+			}
+		}
+	}
+	executed := r.TestsExecuted
+
+	for id, patterns := range tests {
+		sort.Strings(patterns)
+		result := &ControlResult{
+			Control: id,
+			Status:  CONTROL_PASS,
+			Tests:   patterns,
+		}
+		tested := executed == nil
+		for _, pattern := range patterns {
+			for _, name := range failed {
+				if match, _ := path.Match(pattern, name); match {
+					result.Failed = append(result.Failed, name)
+				}
+			}
+			for _, name := range executed {
+				if match, _ := path.Match(pattern, name); match {
+					tested = true
+				}
+			}
+		}
+
+		switch {
+		case len(result.Failed) > 0:
+			result.Status = CONTROL_FAIL
+			cr.Failed++
+		case !tested:
+			result.Status = CONTROL_NOT_TESTED
+			cr.NotTested++
+		default:
+			cr.Passed++
+		}
+		cr.Controls = append(cr.Controls, result)
+	}
+
+	sort.Slice(cr.Controls, func(i, j int) bool {
+		return controlLess(cr.Controls[i].Control, cr.Controls[j].Control)
+	})
+	return cr, nil
+}
+
+// Compares control identifiers so numbered parts are in numerical order eg.
+// 2.1 before 10.1
+func controlLess(a, b string) bool {
+	split := func(r rune) bool { return r == '.' || r == '-' }
+	as, bs := strings.FieldsFunc(a, split), strings.FieldsFunc(b, split)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		if aerr == nil && berr == nil {
+			return an < bn
+		}
+		return as[i] < bs[i]
+	}
+	return len(as) < len(bs)
+}
+
+// Serializes the compliance report as JSON and writes it to Writer
+func (cr *ComplianceReport) SerializeForJSON(w io.Writer) error {
+	data, err := json.Marshal(cr)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Serializes the compliance report as a text table and writes it to Writer
+func (cr *ComplianceReport) SerializeForText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Framework:  %s\n", cr.Name)
+	fmt.Fprintf(tw, "Passed:     %d\n", cr.Passed)
+	fmt.Fprintf(tw, "Failed:     %d\n", cr.Failed)
+	fmt.Fprintf(tw, "Not tested: %d\n", cr.NotTested)
+	fmt.Fprintln(tw, "")
+	fmt.Fprintln(tw, "CONTROL\tSTATUS\tFAILED TESTS")
+	for _, c := range cr.Controls {
+		failed := strings.Join(c.Failed, ",")
+		if len(failed) < 1 {
+			failed = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Control, c.Status, failed)
+	}
+
+	return tw.Flush()
+}
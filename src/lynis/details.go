@@ -0,0 +1,138 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TestDetail stores data collected by a test about a service, eg.
+//
+//	details[]=SSH-7408|sshd|desc:sshd option PermitRootLogin;field:PermitRootLogin;prefval:NO;value:YES;|
+type TestDetail struct {
+	Service     string            `json:"service"`
+	Description string            `json:"description,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	Data        string            `json:"data"`
+	Suppressed  bool              `json:"suppressed,omitempty"`
+}
+
+// Adds the details of a test to the test with the ID of the first value
+func (r *Report) addDetail(value string) error {
+	values := strings.Split(strings.TrimSuffix(value, "|"), "|")
+	if len(values) < 2 || len(values[0]) < 1 {
+		return errors.New(fmt.Sprintf(
+			"malformed details %s no test name or service", value))
+	}
+
+	detail := &TestDetail{
+		Service: values[1],
+		Fields:  make(map[string]string),
+		Data:    strings.Join(values[2:], "|"),
+	}
+	for _, field := range strings.Split(detail.Data, ";") {
+		k, v, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		if k == "desc" {
+			detail.Description = v
+		} else {
+			detail.Fields[k] = v
+		}
+	}
+
+	test := r.AddTest(values[0])
+	test.Details = append(test.Details, detail)
+	return nil
+}
+
+// Adds a manual check to the test it starts with eg. AUTH-9308:01 or
+// AUTH-9308|Check boot loader password, manual checks that do not start with
+// a test are added to the report
+func (r *Report) addManual(value string) {
+	id := value
+	if i := strings.IndexAny(value, ":|"); i > 0 {
+		id = value[:i]
+	}
+	if !catalogueTestID.MatchString(id) {
+		r.Manual = append(r.Manual, value)
+		return
+	}
+
+	message := strings.Trim(strings.TrimPrefix(value, id), "|")
+	if strings.HasPrefix(message, ":") {
+		message = value
+	}
+	test := r.AddTest(id)
+	test.Manual = append(test.Manual, &TestElement{
+		Message:  message,
+		Severity: severityModel.Severity(id, "manual"),
+	})
+}
+
+// Creates a test element from details of a test
+func (d *TestDetail) TestElement() *TestElement {
+	message := d.Description
+	if len(message) < 1 {
+		message = d.Service
+	}
+	return &TestElement{
+		Message:    message,
+		Details:    d.Data,
+		Suppressed: d.Suppressed,
+	}
+}
+
+// Creates flattened elements of the values of the report that do not belong
+// to a test. The name of each element is the key of the value
+func (r *Report) createEntryElastics() []*TestElementElastic {
+	tees := make([]*TestElementElastic, 0)
+	add := func(typ, key string, values ...string) {
+		t := NewTest(key, r)
+		for _, v := range values {
+			tee, _ := CreateTestElementElastic(typ, r, t,
+				&TestElement{Message: v})
+			tees = append(tees, tee)
+		}
+	}
+
+	add("manual", "manual", r.Manual...)
+	add("deleted_file", strings.TrimSuffix(KEY_DELETED_FILE, "[]"),
+		r.DeletedFiles...)
+	if len(r.USBStorage) > 0 {
+		add("usb_storage", KEY_USB_STORAGE, r.USBStorage)
+	}
+	for _, p := range r.EnabledPlugins {
+		t := NewTest(p.key(), r)
+		tee, _ := CreateTestElementElastic("plugin", r, t,
+			&TestElement{Message: p.Name, Details: p.Version})
+		tees = append(tees, tee)
+	}
+	for _, values := range []struct {
+		typ    string
+		prefix string
+		values map[string][]string
+	}{
+		{"firewall", KEY_FIREWALL_PREFIX, r.Firewall},
+		{"plugin", KEY_PLUGIN_PREFIX, r.Plugins},
+	} {
+		keys := make([]string, 0, len(values.values))
+		for k := range values.values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			add(values.typ, values.prefix+k, values.values[k]...)
+		}
+	}
+
+	return tees
+}
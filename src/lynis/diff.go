@@ -0,0 +1,267 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Status of a finding when comparing two reports
+const (
+	DIFF_NEW        string = "new"        // finding only in new report
+	DIFF_RESOLVED   string = "resolved"   // finding only in old report
+	DIFF_PERSISTING string = "persisting" // finding in both reports
+)
+
+// ReportDiff is the result of comparing an old and new report
+type ReportDiff struct {
+	Findings            []*DiffFinding    `json:"findings"`
+	HardeningIndexDelta int               `json:"hardening_index_delta"`
+	Changes             []*MetadataChange `json:"changes"`
+	Old                 *Report           `json:"-"`
+	New                 *Report           `json:"-"`
+}
+
+// DiffFinding is a finding along with whether it is new, resolved or
+// persisting
+type DiffFinding struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Details  string `json:"details"`
+	Solution string `json:"solution"`
+	Severity string `json:"severity"`
+}
+
+// MetadataChange is a report field that differs between the reports
+type MetadataChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// DiffElementElastic is a flattened DiffFinding with details about the new
+// report so it can be ingested into Elasticsearch. Change is the status of
+// the finding in the diff
+type DiffElementElastic struct {
+	TestElementElastic
+	Change string `json:"change"`
+}
+
+// Returns key identifying a finding across reports
+func diffKey(name, typ string, te *TestElement) string {
+	return name + "|" + typ + "|" + te.Message
+}
+
+// Compares the findings and metadata of an old and new report. Findings are
+// the same if they are for the same test, have the same type and the same
+// message
+func Diff(a, b *Report) *ReportDiff {
+	diff := &ReportDiff{
+		Findings:            make([]*DiffFinding, 0),
+		HardeningIndexDelta: b.HardeningIndex - a.HardeningIndex,
+		Changes:             make([]*MetadataChange, 0),
+		Old:                 a,
+		New:                 b,
+	}
+
+	// count findings of old report, a finding may appear more than once
+	old := make(map[string]int)
+	for _, t := range a.Tests {
+		for _, f := range t.Findings() {
+			old[diffKey(t.Name, f.Type, f.Element)]++
+		}
+	}
+
+	// findings of new report are either new or persisting
+	for _, name := range b.TestNames() {
+		t := b.Tests[name]
+		for _, f := range t.Findings() {
+			status := DIFF_NEW
+			key := diffKey(name, f.Type, f.Element)
+			if old[key] > 0 {
+				old[key]--
+				status = DIFF_PERSISTING
+			}
+			diff.Findings = append(diff.Findings, newDiffFinding(f, status))
+		}
+	}
+
+	// remaining findings of old report have been resolved
+	for _, name := range a.TestNames() {
+		t := a.Tests[name]
+		for _, f := range t.Findings() {
+			key := diffKey(name, f.Type, f.Element)
+			if old[key] > 0 {
+				old[key]--
+				diff.Findings = append(diff.Findings,
+					newDiffFinding(f, DIFF_RESOLVED))
+			}
+		}
+	}
+
+	sort.SliceStable(diff.Findings, func(i, j int) bool {
+		return diff.Findings[i].Name < diff.Findings[j].Name
+	})
+
+	// compare metadata of reports
+	for _, field := range []struct {
+		name     string
+		old, new string
+	}{
+		{"lynisVersion", a.LynisVersion, b.LynisVersion},
+		{"hostname", a.Hostname, b.Hostname},
+		{"hostid", a.HostID, b.HostID},
+		{"hardening_index", strconv.Itoa(a.HardeningIndex),
+			strconv.Itoa(b.HardeningIndex)},
+	} {
+		if field.old != field.new {
+			diff.Changes = append(diff.Changes,
+				&MetadataChange{field.name, field.old, field.new})
+		}
+	}
+
+	return diff
+}
+
+// Creates a DiffFinding from a finding, it keeps the severity of the finding
+func newDiffFinding(f *Finding, status string) *DiffFinding {
+	return &DiffFinding{
+		Name:     f.Test.Name,
+		Type:     f.Type,
+		Status:   status,
+		Message:  f.Element.Message,
+		Details:  f.Element.Details,
+		Solution: f.Element.Solution,
+		Severity: f.Severity(),
+	}
+}
+
+// Returns the amount of new, resolved and persisting findings
+func (d *ReportDiff) Count() (added, resolved, persisting int) {
+	for _, f := range d.Findings {
+		switch f.Status {
+		case DIFF_NEW:
+			added++
+		case DIFF_RESOLVED:
+			resolved++
+		case DIFF_PERSISTING:
+			persisting++
+		}
+	}
+	return
+}
+
+// Returns a copy of the diff that only contains findings with the status
+func (d *ReportDiff) Only(status string) *ReportDiff {
+	only := *d
+	only.Findings = make([]*DiffFinding, 0)
+	for _, f := range d.Findings {
+		if f.Status == status {
+			only.Findings = append(only.Findings, f)
+		}
+	}
+	return &only
+}
+
+// Serializes the diff as JSON and writes it to Writer
+func (d *ReportDiff) SerializeForJSON(w io.Writer) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Serializes every finding of the diff as a flattened JSON object seperated
+// by new lines and writes them to Writer
+func (d *ReportDiff) SerializeForElasticSearch(w io.Writer) error {
+	for _, f := range d.Findings {
+		info := catalogue.Lookup(f.Name)
+		data, err := json.Marshal(&DiffElementElastic{
+			TestElementElastic: TestElementElastic{
+				Name:          f.Name,
+				Type:          f.Type,
+				Category:      info.Category,
+				Title:         info.Title,
+				Reference:     info.Reference,
+				LynisVersion:  d.New.LynisVersion,
+				DateTimeStart: d.New.DateTimeStart,
+				DateTimeEnd:   d.New.DateTimeEnd,
+				Message:       f.Message,
+				Details:       f.Details,
+				Solution:      f.Solution,
+				Severity:      f.Severity,
+				Controls:      complianceMapping.Controls(f.Name),
+			},
+			Change: f.Status,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Serializes the diff into a human readable summary and writes it to Writer
+func (d *ReportDiff) SerializeForText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	added, resolved, persisting := d.Count()
+
+	fmt.Fprintf(buf, "New:             %d\n", added)
+	fmt.Fprintf(buf, "Resolved:        %d\n", resolved)
+	fmt.Fprintf(buf, "Persisting:      %d\n", persisting)
+	fmt.Fprintf(buf, "Hardening index: %d (%+d)\n", d.New.HardeningIndex,
+		d.HardeningIndexDelta)
+	for _, c := range d.Changes {
+		fmt.Fprintf(buf, "Changed %s: %s -> %s\n", c.Field, c.Old, c.New)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	// list findings by status
+	for _, section := range []struct {
+		heading string
+		status  string
+		sign    string
+	}{
+		{"New", DIFF_NEW, "+"},
+		{"Resolved", DIFF_RESOLVED, "-"},
+		{"Persisting", DIFF_PERSISTING, " "},
+	} {
+		buf.Reset()
+		for _, f := range d.Findings {
+			if f.Status == section.status {
+				fmt.Fprintf(buf, "%s [%s] %s: %s\n", section.sign, f.Name,
+					f.Type, f.Message)
+			}
+		}
+		if buf.Len() < 1 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "\n%s:\n", section.heading); err != nil {
+			return err
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
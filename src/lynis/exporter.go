@@ -0,0 +1,153 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Content type of metrics served in the OpenMetrics format
+const (
+	OPENMETRICS_CONTENT_TYPE string = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Exporter serves metrics about Lynis reports over HTTP. Report files are
+// re-read when they change so the metrics always describe the latest scan
+type Exporter struct {
+	Paths   []string               // report files to export
+	mu      sync.Mutex             // guards reports
+	reports map[string]*reportFile // state of each report file by path
+	now     func() time.Time       // returns current time
+}
+
+// State of a report file watched by the Exporter
+type reportFile struct {
+	modTime     time.Time // modification time of file when last read
+	size        int64     // size of file when last read
+	report      *Report   // report parsed from file, nil if parsing failed
+	parseErrors int       // amount of times reading the file failed
+}
+
+// Creates a new Exporter for the report files
+func NewExporter(paths ...string) *Exporter {
+	return &Exporter{
+		Paths:   paths,
+		reports: make(map[string]*reportFile),
+		now:     time.Now,
+	}
+}
+
+// Re-reads report files that have changed since they were last read
+func (e *Exporter) Refresh() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, path := range e.Paths {
+		rf, ok := e.reports[path]
+		if !ok {
+			rf = &reportFile{}
+			e.reports[path] = rf
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			// file has been removed or can't be read
+			if rf.size >= 0 {
+				rf.parseErrors++
+			}
+			rf.report = nil
+			rf.size = -1
+			continue
+		}
+
+		// skip files that have not changed
+		if info.ModTime().Equal(rf.modTime) && info.Size() == rf.size {
+			continue
+		}
+		rf.modTime = info.ModTime()
+		rf.size = info.Size()
+
+		report, err := ReadReportFile(path)
+		if err != nil {
+			rf.parseErrors++
+		}
+		rf.report = report
+	}
+}
+
+// Creates metric families for all report files, every sample is labeled with
+// the path of its report
+func (e *Exporter) metrics() []*promMetric {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	up := &promMetric{"lynis_report_up",
+		"Whether the last read of the report file succeeded", "gauge", nil}
+	errs := &promMetric{"lynis_report_parse_errors_total",
+		"Number of times reading the report file failed", "counter", nil}
+	age := &promMetric{"lynis_report_age_seconds",
+		"Seconds since the Lynis scan of the report finished", "gauge", nil}
+	metrics := []*promMetric{up, errs, age}
+	families := make(map[string]*promMetric)
+
+	now := e.now()
+	for _, path := range e.Paths {
+		rf, ok := e.reports[path]
+		if !ok {
+			continue
+		}
+		label := []string{"report", path}
+
+		errs.samples = append(errs.samples,
+			promSample{labels: label, value: rf.parseErrors})
+		if rf.report == nil {
+			up.samples = append(up.samples,
+				promSample{labels: label, value: 0})
+			continue
+		}
+		up.samples = append(up.samples, promSample{labels: label, value: 1})
+
+		if end, err := ParseTime(rf.report.DateTimeEnd); err == nil {
+			age.samples = append(age.samples, promSample{
+				labels: label,
+				value:  now.Sub(end).Seconds(),
+			})
+		}
+
+		// merge report metrics into families shared by all reports
+		for _, m := range rf.report.prometheusMetrics() {
+			family, ok := families[m.name]
+			if !ok {
+				family = &promMetric{m.name, m.help, m.typ, nil}
+				families[m.name] = family
+				metrics = append(metrics, family)
+			}
+			for _, s := range m.samples {
+				s.labels = append(append([]string{}, label...),
+					s.labels...)
+				family.samples = append(family.samples, s)
+			}
+		}
+	}
+
+	return metrics
+}
+
+// Serves the metrics of all report files in the OpenMetrics format
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.Refresh()
+
+	buf := &bytes.Buffer{}
+	writeMetrics(buf, e.metrics(), true)
+
+	w.Header().Set("Content-Type", OPENMETRICS_CONTENT_TYPE)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,267 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Finding is a single warning or suggestion along with the test it belongs to
+type Finding struct {
+	Test    *Test        // test finding belongs to
+	Type    string       // type of finding eg. warning or suggestion
+	Element *TestElement // details of finding
+}
+
+// Filter decides which findings of a report are kept
+type Filter interface {
+	// returns true if the finding matches the filter
+	Match(*Finding) bool
+}
+
+// FilterFunc is a function that can be used as a Filter
+type FilterFunc func(*Finding) bool
+
+// Calls the function with the finding
+func (ff FilterFunc) Match(f *Finding) bool {
+	return ff(f)
+}
+
+// Creates a Filter that matches findings of the type
+func TypeFilter(typ string) Filter {
+	return FilterFunc(func(f *Finding) bool {
+		return f.Type == typ
+	})
+}
+
+// Creates a Filter that matches findings of tests with names matching the
+// glob pattern eg. NETW-*
+func TestFilter(pattern string) (Filter, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return FilterFunc(func(f *Finding) bool {
+		match, _ := path.Match(pattern, f.Test.Name)
+		return match
+	}), nil
+}
+
+// Creates a Filter that matches findings of tests in the category given as
+// the prefix of the test eg. NETW or the name of the category eg. Networking
+func CategoryFilter(category string) Filter {
+	return FilterFunc(func(f *Finding) bool {
+		return strings.EqualFold(TestCategory(f.Test.Name), category) ||
+			(len(f.Test.Category) > 0 &&
+				strings.EqualFold(f.Test.Category, category))
+	})
+}
+
+// Creates a Filter that matches findings with a message matching the regular
+// expression
+func MessageFilter(re *regexp.Regexp) Filter {
+	return FilterFunc(func(f *Finding) bool {
+		return re.MatchString(f.Element.Message)
+	})
+}
+
+// Creates a Filter that matches findings with details matching the regular
+// expression
+func DetailsFilter(re *regexp.Regexp) Filter {
+	return FilterFunc(func(f *Finding) bool {
+		return re.MatchString(f.Element.Details)
+	})
+}
+
+// Creates a Filter from an expression. Supported expressions are
+//
+//	type=TYPE        findings of type eg. warning, suggestion or detail
+//	test=GLOB        findings of tests matching glob pattern eg. NETW-*
+//	category=NAME    findings of tests in category eg. NETW
+//	message~REGEX    findings with message matching regular expression
+//	details~REGEX    findings with details matching regular expression
+//	severity=LEVEL   findings of severity eg. high
+//	severity>=LEVEL  findings of severity or more severe
+func ParseFilter(expr string) (Filter, error) {
+	if field, value, ok := strings.Cut(expr, "~"); ok &&
+		!strings.Contains(field, "=") {
+
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.TrimSpace(field) {
+		case "message":
+			return MessageFilter(re), nil
+		case "details":
+			return DetailsFilter(re), nil
+		}
+		return nil, errors.New(fmt.Sprintf(
+			"invalid filter %s, only message and details support ~", expr))
+	}
+
+	field, value, ok := strings.Cut(expr, "=")
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("invalid filter %s", expr))
+	}
+	switch strings.TrimSpace(field) {
+	case "type":
+		return TypeFilter(value), nil
+	case "test":
+		return TestFilter(value)
+	case "category":
+		return CategoryFilter(value), nil
+	case "severity":
+		if err := ValidateSeverity(value); err != nil {
+			return nil, err
+		}
+		return FilterFunc(func(f *Finding) bool {
+			return f.Severity() == value
+		}), nil
+	case "severity>":
+		if err := ValidateSeverity(value); err != nil {
+			return nil, err
+		}
+		return SeverityFilter(value), nil
+	}
+	return nil, errors.New(fmt.Sprintf("invalid filter %s, unknown field %s",
+		expr, field))
+}
+
+// Creates a Filter that keeps findings matching any of the include filters
+// and none of the exclude filters. All findings are included if there are no
+// include filters
+func IncludeExclude(include, exclude []Filter) Filter {
+	return FilterFunc(func(f *Finding) bool {
+		for _, ex := range exclude {
+			if ex.Match(f) {
+				return false
+			}
+		}
+		if len(include) < 1 {
+			return true
+		}
+		for _, in := range include {
+			if in.Match(f) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Returns a copy of the report that only contains findings that match the
+// filter. Manual checks, details and values of the report that do not belong
+// to a test are filtered the same as findings, by the type and name they have
+// in flattened outputs. Tests without any remaining findings or details are
+// removed
+func (r *Report) Filter(keep Filter) *Report {
+	filtered := *r
+	filtered.Tests = make(map[string]*Test)
+
+	for name, t := range r.Tests {
+		ft := NewTest(name, &filtered)
+		for _, w := range t.Warnings {
+			if keep.Match(&Finding{t, "warning", w}) {
+				AddWarning(ft, w)
+			}
+		}
+		for _, s := range t.Suggestions {
+			if keep.Match(&Finding{t, "suggestion", s}) {
+				AddSuggestion(ft, s)
+			}
+		}
+		for _, m := range t.Manual {
+			if keep.Match(&Finding{t, "manual", m}) {
+				ft.Manual = append(ft.Manual, m)
+			}
+		}
+		for _, d := range t.Details {
+			if keep.Match(&Finding{t, "detail", d.TestElement()}) {
+				ft.Details = append(ft.Details, d)
+			}
+		}
+
+		if len(ft.Warnings)+len(ft.Suggestions)+len(ft.Manual)+
+			len(ft.Details) > 0 {
+			filtered.Tests[name] = ft
+		}
+	}
+
+	// values of the report that do not belong to a test
+	entry := func(typ, key string, te *TestElement) bool {
+		return keep.Match(&Finding{NewTest(key, &filtered), typ, te})
+	}
+	filtered.Manual = make([]string, 0, len(r.Manual))
+	for _, m := range r.Manual {
+		if entry("manual", "manual", &TestElement{Message: m}) {
+			filtered.Manual = append(filtered.Manual, m)
+		}
+	}
+	filtered.DeletedFiles = make([]string, 0, len(r.DeletedFiles))
+	for _, f := range r.DeletedFiles {
+		if entry("deleted_file", strings.TrimSuffix(KEY_DELETED_FILE, "[]"),
+			&TestElement{Message: f}) {
+			filtered.DeletedFiles = append(filtered.DeletedFiles, f)
+		}
+	}
+	if len(r.USBStorage) > 0 && !entry("usb_storage", KEY_USB_STORAGE,
+		&TestElement{Message: r.USBStorage}) {
+		filtered.USBStorage = ""
+	}
+	filtered.EnabledPlugins = make([]*Plugin, 0, len(r.EnabledPlugins))
+	for _, p := range r.EnabledPlugins {
+		if entry("plugin", p.key(), &TestElement{Message: p.Name,
+			Details: p.Version}) {
+			filtered.EnabledPlugins = append(filtered.EnabledPlugins, p)
+		}
+	}
+	filterValues := func(typ, prefix string,
+		values map[string][]string) map[string][]string {
+
+		kept := make(map[string][]string)
+		for k, vs := range values {
+			for _, v := range vs {
+				if entry(typ, prefix+k, &TestElement{Message: v}) {
+					kept[k] = append(kept[k], v)
+				}
+			}
+		}
+		return kept
+	}
+	filtered.Firewall = filterValues("firewall", KEY_FIREWALL_PREFIX,
+		r.Firewall)
+	filtered.Plugins = filterValues("plugin", KEY_PLUGIN_PREFIX, r.Plugins)
+
+	return &filtered
+}
+
+// OutputFormatter that removes findings from the report that don't match the
+// filter before passing it to the next formatter, it does not write output
+type FormatFilter struct {
+	Filter Filter
+	next   OutputFormatter
+}
+
+// Filters the report and executes the next formatter with it
+func (ff *FormatFilter) Format(report *Report, w io.Writer) error {
+	return formatNext(ff, report.Filter(ff.Filter), w)
+}
+
+// Gets next formatter
+func (ff *FormatFilter) Next() OutputFormatter {
+	return ff.next
+}
+
+// Sets next formatter
+func (ff *FormatFilter) SetNext(next OutputFormatter) {
+	ff.next = next
+}
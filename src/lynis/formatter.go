@@ -7,28 +7,33 @@ package lynis
  */
 
 import (
-	"bytes"
 	"encoding/json"
 	"io"
 )
 
-// Process the report by reading from Reader and formatting with the
-// OutputFormatter, returns the pointer to the Report struct and a byte array
-// of the serialized report
-func Process(input io.Reader, output OutputFormatter) (*Report, []byte, error) {
+// Process the report by reading from Reader and writing it to Writer with the
+// OutputFormatter, returns the pointer to the Report struct
+func Process(input io.Reader, w io.Writer, output OutputFormatter) (*Report, error) {
 	// CreateReport object from input
 	report, err := CreateReport(input)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return output.Format(report, nil, nil)
+	return report, Write(report, w, output)
 }
 
-// OutputFormatter interface for outputing the Report struct to specific format
+// Writes the report to Writer with the chain of formatters starting at head
+func Write(report *Report, w io.Writer, head OutputFormatter) error {
+	return head.Format(report, &stream{w: w})
+}
+
+// OutputFormatter interface for outputing the Report struct to specific
+// format. Formatters are executed in the order of the chain and each writes
+// its output to the Writer as it is produced
 type OutputFormatter interface {
-	// format report into byte slice
-	Format(*Report, []byte, error) (*Report, []byte, error)
+	// format report and write it to Writer
+	Format(*Report, io.Writer) error
 
 	// Format to execute after
 	Next() OutputFormatter
@@ -51,44 +56,61 @@ func SetNext(head OutputFormatter, next OutputFormatter) {
 	prev.SetNext(next)
 }
 
-// OutputFormatter that will format report as a JSON string
-type FormatJSON struct {
-	next OutputFormatter
+// Executes the formatter after of if it exists
+func formatNext(of OutputFormatter, report *Report, w io.Writer) error {
+	if of.Next() != nil {
+		return of.Next().Format(report, w)
+	}
+	return nil
 }
 
-// Serializes Report into Json byte slice and returns the Report pointer, and
-// byte slice
-func (fj *FormatJSON) Format(report *Report,
-	data []byte, err error) (*Report, []byte, error) {
+// Writer passed along the formatter chain that keeps track of how much has
+// been written so formatters can seperate their output from the output of
+// previous formatters
+type stream struct {
+	w io.Writer
+	n int64
+}
 
-	// if error exists return the error
-	if err != nil {
-		return nil, nil, err
+// Writes to the underlying Writer
+func (s *stream) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.n += int64(n)
+	return n, err
+}
+
+// Writes a space if a previous formatter has written output
+func separate(w io.Writer) error {
+	if s, ok := w.(*stream); ok && s.n > 0 {
+		_, err := w.Write([]byte{' '})
+		return err
 	}
+	return nil
+}
+
+// OutputFormatter that will format report as a JSON string
+type FormatJSON struct {
+	next OutputFormatter
+}
 
+// Serializes Report into Json and writes it to Writer
+func (fj *FormatJSON) Format(report *Report, w io.Writer) error {
 	// marshal the Report struct into byte slice
-	newdata, err := json.Marshal(report)
+	data, err := json.Marshal(report)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 
-	if data == nil {
-		data = newdata
-	} else {
-		// if data already exists in slice
-		// append json string and add space between data
-		buf := bytes.NewBuffer(data)
-		buf.WriteRune(' ')
-		buf.Write(newdata)
-		data = buf.Bytes()
+	// add space between data if data has already been written
+	if err := separate(w); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
 	}
 
 	// execute the next formatter if it exists
-	if fj.Next() != nil {
-		return fj.Next().Format(report, data, nil)
-	} else {
-		return report, data, nil
-	}
+	return formatNext(fj, report, w)
 }
 
 // Returns the next formatter
@@ -110,38 +132,24 @@ type FormatElasticJSON struct {
 	next OutputFormatter
 }
 
-// Serializes Report into multiple Json byte slices seperated by new lines and
-// returns the Report pointer, and byte slice
-func (fj *FormatElasticJSON) Format(report *Report,
-	data []byte, err error) (*Report, []byte, error) {
-
-	// return error if it exists
-	if err != nil {
-		return nil, nil, err
+// Serializes a summary of the Report and its TestElements into multiple Json
+// strings seperated by new lines and writes them to Writer
+func (fj *FormatElasticJSON) Format(report *Report, w io.Writer) error {
+	// add space between data if data has already been written
+	if err := separate(w); err != nil {
+		return err
 	}
 
-	// serialize TestElements into multiple JSON strings
-	newdata, err := report.SerializeForElasticSearch()
-	if err != nil {
-		return nil, nil, err
+	// serialize summary and TestElements into multiple JSON strings
+	if err := report.SerializeSummary(w); err != nil {
+		return err
 	}
-
-	if data == nil {
-		data = newdata
-	} else {
-		// append serialized data if it exists
-		buf := bytes.NewBuffer(data)
-		buf.WriteRune(' ')
-		buf.Write(newdata)
-		data = buf.Bytes()
+	if err := report.SerializeForElasticSearch(w); err != nil {
+		return err
 	}
 
 	// execute next formatter if it exists
-	if fj.Next() != nil {
-		return fj.Next().Format(report, data, nil)
-	} else {
-		return report, data, nil
-	}
+	return formatNext(fj, report, w)
 }
 
 // Gets next formatter
@@ -154,37 +162,24 @@ func (fj *FormatElasticJSON) SetNext(next OutputFormatter) {
 	fj.next = next
 }
 
-// Formatter that adds timestamp to beginning of serialized data
+// Formatter that writes timestamp of report, it should be placed at the
+// beginning of the chain so the timestamp is written before the data
 type FormatTimestamp struct {
 	next OutputFormatter
 }
 
-// Serializes Report.DateTimeEnd field and adds it to beginning to serialized
-// byte slice
-func (ft *FormatTimestamp) Format(report *Report,
-	data []byte, err error) (*Report, []byte, error) {
-
-        // retun error if it exists
-	if err != nil {
-		return nil, nil, err
+// Writes Report.DateTimeEnd field to Writer
+func (ft *FormatTimestamp) Format(report *Report, w io.Writer) error {
+	// add space between data if data has already been written
+	if err := separate(w); err != nil {
+		return err
 	}
-
-	if data == nil {
-		data = []byte(report.DateTimeEnd)
-	} else {
-                // adds timestamp to beginning of list
-		buf := bytes.NewBufferString(report.DateTimeEnd)
-		buf.WriteRune(' ')
-		buf.Write(data)
-		data = buf.Bytes()
+	if _, err := io.WriteString(w, report.DateTimeEnd); err != nil {
+		return err
 	}
 
-        // exexute next formatter
-	if ft.Next() != nil {
-		return ft.Next().Format(report, data, nil)
-	} else {
-		return report, data, nil
-	}
+	// exexute next formatter
+	return formatNext(ft, report, w)
 }
 
 // Get next formatter
@@ -197,34 +192,19 @@ func (ft *FormatTimestamp) SetNext(next OutputFormatter) {
 	ft.next = next
 }
 
-// Formatter that appends a new line character to end of serialized data slice
+// Formatter that writes a new line character after the serialized data
 type FormatNewLine struct {
 	next OutputFormatter
 }
 
-
-// Appends new line character to end of serialized byte slice 
-func (fnl *FormatNewLine) Format(report *Report,
-	data []byte, err error) (*Report, []byte, error) {
-        
-        // return error if it exists
-	if err != nil {
-		return nil, nil, err
-	}
-
-	if data == nil {
-		data = []byte{'\n'}
-	} else {
-                // append new line character
-		data = append(data, []byte{'\n'}...)
+// Writes new line character to Writer
+func (fnl *FormatNewLine) Format(report *Report, w io.Writer) error {
+	if _, err := w.Write([]byte{'\n'}); err != nil {
+		return err
 	}
 
-        // exexcute next formatter
-	if fnl.Next() != nil {
-		return fnl.Next().Format(report, data, nil)
-	} else {
-		return report, data, nil
-	}
+	// exexcute next formatter
+	return formatNext(fnl, report, w)
 }
 
 // Get next formatter
@@ -0,0 +1,245 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KeyHandler processes the value of a key of a Lynis report and adds it to
+// the report
+type KeyHandler func(r *Report, key, value string) error
+
+// Handlers of report keys starting with a prefix
+type prefixHandler struct {
+	prefix string
+	handle KeyHandler
+}
+
+// Handlers of exact report keys, keys of arrays include the trailing []
+var keyHandlers = make(map[string]KeyHandler)
+
+// Handlers of report keys by prefix, sorted by longest prefix first
+var prefixHandlers = make([]*prefixHandler, 0)
+
+// Plugin enabled during a scan
+type Plugin struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Phase   int    `json:"phase"`
+}
+
+// Registers the built in handlers of the keys of a Lynis report
+func init() {
+	// scan
+	registerKeyHandler(KEY_LYNISVER, func(r *Report, _, value string) error {
+		r.LynisVersion = value
+		return CheckVersion(value)
+	})
+	registerKeyHandler(KEY_REPORT_DATETIME_START,
+		func(r *Report, _, value string) (err error) {
+			r.DateTimeStart, err = FormatTime(value)
+			return
+		})
+	registerKeyHandler(KEY_REPORT_DATETIME_END,
+		func(r *Report, _, value string) (err error) {
+			r.DateTimeEnd, err = FormatTime(value)
+			return
+		})
+	registerKeyHandler(KEY_HOSTNAME, func(r *Report, _, value string) error {
+		r.Hostname = value
+		return nil
+	})
+	registerKeyHandler(KEY_HOSTID, func(r *Report, _, value string) error {
+		r.HostID = value
+		return nil
+	})
+	registerKeyHandler(KEY_HARDENING_INDEX,
+		func(r *Report, _, value string) (err error) {
+			r.HardeningIndex, err = strconv.Atoi(value)
+			return
+		})
+	registerKeyHandler(KEY_TESTS_EXECUTED,
+		func(r *Report, _, value string) error {
+			r.TestsExecuted = parseList(value)
+			return nil
+		})
+	registerKeyHandler(KEY_TESTS_SKIPPED,
+		func(r *Report, _, value string) error {
+			r.TestsSkipped = parseList(value)
+			return nil
+		})
+	registerKeyHandler(KEY_LYNIS_TESTS_DONE,
+		func(r *Report, _, value string) (err error) {
+			r.LynisTestsDone, err = strconv.Atoi(value)
+			return
+		})
+	registerKeyHandler(KEY_FINISH, func(r *Report, _, value string) (err error) {
+		r.Finished, err = strconv.ParseBool(value)
+		return
+	})
+	registerKeyHandler(KEY_AUDITOR, func(r *Report, _, value string) error {
+		r.Auditor = value
+		return nil
+	})
+	registerKeyHandler(KEY_REPORT_VERSION_MAJOR,
+		func(r *Report, _, value string) (err error) {
+			// check report format is supported
+			r.VersionMajor, err = strconv.Atoi(value)
+			if err == nil && r.VersionMajor != REPORT_VERSION_MAJOR {
+				err = errors.New(fmt.Sprintf(
+					"report format version %d is not supported",
+					r.VersionMajor))
+			}
+			return
+		})
+	registerKeyHandler(KEY_REPORT_VERSION_MINOR,
+		func(r *Report, _, value string) (err error) {
+			r.VersionMinor, err = strconv.Atoi(value)
+			return
+		})
+
+	// findings and test data
+	registerKeyHandler(KEY_WARNING, func(r *Report, _, value string) error {
+		_, err := r.parseTestValues(value, AddWarning)
+		return err
+	})
+	registerKeyHandler(KEY_SUGGESTION, func(r *Report, _, value string) error {
+		_, err := r.parseTestValues(value, AddSuggestion)
+		return err
+	})
+	registerKeyHandler(KEY_MANUAL, func(r *Report, _, value string) error {
+		r.addManual(value)
+		return nil
+	})
+	registerKeyHandler(KEY_DETAILS, func(r *Report, _, value string) error {
+		return r.addDetail(value)
+	})
+
+	// system
+	registerKeyHandler(KEY_DELETED_FILE, func(r *Report, _, value string) error {
+		r.DeletedFiles = append(r.DeletedFiles, value)
+		return nil
+	})
+	registerKeyHandler(KEY_USB_STORAGE, func(r *Report, _, value string) error {
+		r.USBStorage = value
+		return nil
+	})
+	registerKeyHandler(KEY_FIREWALL_PREFIX+"*",
+		func(r *Report, key, value string) error {
+			addValue(r.Firewall, strings.TrimPrefix(key, KEY_FIREWALL_PREFIX),
+				value)
+			return nil
+		})
+
+	// plugins
+	registerKeyHandler(KEY_PLUGINS_ENABLED,
+		func(r *Report, _, value string) (err error) {
+			r.PluginsEnabled, err = strconv.ParseBool(value)
+			return
+		})
+	registerKeyHandler(KEY_PLUGIN_ENABLED_PHASE1, addPlugin(1))
+	registerKeyHandler(KEY_PLUGIN_ENABLED_PHASE2, addPlugin(2))
+	// values of plugins depend on the plugin and are kept as raw strings
+	registerKeyHandler(KEY_PLUGIN_PREFIX+"*",
+		func(r *Report, key, value string) error {
+			addValue(r.Plugins, strings.TrimPrefix(key, KEY_PLUGIN_PREFIX),
+				value)
+			return nil
+		})
+}
+
+// Registers the handler of report keys matching the pattern so keys that are
+// not supported, eg. keys of custom tests, can be added to the report.
+// Patterns are exact keys, keys of arrays ending with [] eg. custom_check[]
+// or prefixes ending with * eg. custom_*. A handler replaces the handler
+// already registered for the same pattern, including built in handlers, and
+// exact keys take precedence over prefixes. Handlers should be registered
+// before reports are created
+func RegisterKeyHandler(pattern string, handle KeyHandler) error {
+	if handle == nil {
+		return errors.New(fmt.Sprintf("no handler for key pattern %s", pattern))
+	}
+	name := strings.TrimSuffix(strings.TrimSuffix(pattern, "*"), "[]")
+	if len(name) < 1 || strings.ContainsAny(name, "=*[] \t") {
+		return errors.New(fmt.Sprintf("invalid key pattern %s", pattern))
+	}
+	registerKeyHandler(pattern, handle)
+	return nil
+}
+
+// Registers the handler of report keys matching the pattern without
+// validating the pattern
+func registerKeyHandler(pattern string, handle KeyHandler) {
+	prefix, ok := strings.CutSuffix(pattern, "*")
+	if !ok {
+		keyHandlers[pattern] = handle
+		return
+	}
+
+	for _, ph := range prefixHandlers {
+		if ph.prefix == prefix {
+			ph.handle = handle
+			return
+		}
+	}
+	prefixHandlers = append(prefixHandlers, &prefixHandler{prefix, handle})
+	sort.SliceStable(prefixHandlers, func(i, j int) bool {
+		return len(prefixHandlers[i].prefix) > len(prefixHandlers[j].prefix)
+	})
+}
+
+// Returns the handler registered for the key or nil if there is none
+func keyHandler(key string) KeyHandler {
+	if handle, ok := keyHandlers[key]; ok {
+		return handle
+	}
+	for _, ph := range prefixHandlers {
+		if strings.HasPrefix(key, ph.prefix) {
+			return ph.handle
+		}
+	}
+	return nil
+}
+
+// Returns a handler that adds plugins enabled in the phase of the scan. The
+// name and version of the plugin are separated by |
+func addPlugin(phase int) KeyHandler {
+	return func(r *Report, _, value string) error {
+		values := parseList(value)
+		if len(values) < 1 {
+			return errors.New(fmt.Sprintf("malformed plugin %s no name", value))
+		}
+		plugin := &Plugin{Name: values[0], Phase: phase}
+		if len(values) > 1 {
+			plugin.Version = values[1]
+		}
+		r.EnabledPlugins = append(r.EnabledPlugins, plugin)
+		return nil
+	}
+}
+
+// Returns the key of the report the plugin was enabled with eg.
+// plugin_enabled_phase1
+func (p *Plugin) key() string {
+	return fmt.Sprintf("%senabled_phase%d", KEY_PLUGIN_PREFIX, p.Phase)
+}
+
+// Returns the names of the plugins enabled in the phase of the scan
+func (r *Report) pluginNames(phase int) []string {
+	names := make([]string, 0)
+	for _, p := range r.EnabledPlugins {
+		if p.Phase == phase {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,337 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format of the scan time in the file names of stored reports
+const (
+	HISTORY_TIME_FMT string = "20060102T150405Z"
+	HISTORY_EXT      string = ".json.gz"
+)
+
+// History is an append-only store of past reports. Reports are stored as
+// compressed JSON in a directory for each host named after the time of the
+// scan
+//
+//	DIR/HOST/20220405T193619Z.json.gz
+type History struct {
+	Dir string // directory reports are stored in
+}
+
+// HistoryEntry is a report stored in the history
+type HistoryEntry struct {
+	Host string    `json:"host"`
+	Time time.Time `json:"time"`
+	Path string    `json:"path"`
+}
+
+// TestHistory is when a finding of a test was first and last seen in the
+// history of a host
+type TestHistory struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Runs      int       `json:"runs"`
+	Current   bool      `json:"current"`
+}
+
+// Opens the history in the directory creating it if it doesn't exist
+func OpenHistory(dir string) (*History, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	return &History{Dir: dir}, nil
+}
+
+// Returns the key the history of a report is stored under, the host ID is
+// used if it exists otherwise the hostname
+func HistoryHost(r *Report) string {
+	host := r.HostID
+	if len(host) < 1 {
+		host = r.Hostname
+	}
+	if len(host) < 1 {
+		host = "unknown"
+	}
+	// host is used as a directory name
+	return strings.NewReplacer("/", "_", `\`, "_", "..", "_").Replace(host)
+}
+
+// Returns the time of the scan of a report, the end time is used if it
+// exists otherwise the start time
+func ScanTime(r *Report) (time.Time, error) {
+	if scan, err := ParseTime(r.DateTimeEnd); err == nil {
+		return scan, nil
+	}
+	scan, err := ParseTime(r.DateTimeStart)
+	if err != nil {
+		return time.Time{}, errors.New("report does not have a scan time")
+	}
+	return scan, nil
+}
+
+// Saves the report to the history. Reports are never overwritten, saving a
+// report for a scan that is already stored returns the existing entry
+func (h *History) Save(r *Report) (*HistoryEntry, error) {
+	scan, err := ScanTime(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &HistoryEntry{
+		Host: HistoryHost(r),
+		Time: scan.UTC(),
+	}
+	dir := filepath.Join(h.Dir, entry.Host)
+	entry.Path = filepath.Join(dir, entry.Time.Format(HISTORY_TIME_FMT)+
+		HISTORY_EXT)
+
+	// history is append-only
+	if _, err := os.Stat(entry.Path); err == nil {
+		return entry, nil
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	// write compressed JSON atomically
+	pending, err := CreatePendingFile(entry.Path, false, 0640)
+	if err != nil {
+		return nil, err
+	}
+	data, err := marshalHistory(r)
+	if err != nil {
+		pending.Abort()
+		return nil, err
+	}
+	zw := gzip.NewWriter(pending)
+	_, err = zw.Write(data)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = pending.Commit()
+	}
+	if err != nil {
+		pending.Abort()
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Returns the hosts stored in the history
+func (h *History) Hosts() ([]string, error) {
+	entries, err := os.ReadDir(h.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]string, 0)
+	for _, e := range entries {
+		if e.IsDir() {
+			hosts = append(hosts, e.Name())
+		}
+	}
+	return hosts, nil
+}
+
+// Returns the stored reports of the host sorted from oldest to newest
+func (h *History) Runs(host string) ([]*HistoryEntry, error) {
+	dir := filepath.Join(h.Dir, host)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*HistoryEntry, 0)
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, HISTORY_EXT) {
+			continue
+		}
+		scan, err := time.Parse(HISTORY_TIME_FMT,
+			strings.TrimSuffix(name, HISTORY_EXT))
+		if err != nil {
+			continue // not a stored report
+		}
+		runs = append(runs, &HistoryEntry{
+			Host: host,
+			Time: scan,
+			Path: filepath.Join(dir, name),
+		})
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Time.Before(runs[j].Time)
+	})
+	return runs, nil
+}
+
+// Loads a stored report
+func (h *History) Load(entry *HistoryEntry) (*Report, error) {
+	input, err := os.Open(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	zr, err := gzip.NewReader(input)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", entry.Path, err))
+	}
+	defer zr.Close()
+
+	report, err := unmarshalHistory(zr)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", entry.Path, err))
+	}
+	return report, nil
+}
+
+// Serializes the report as JSON to store it in the history. Raw values are
+// not part of the JSON of a report, they are stored along with it so rules
+// can be evaluated on stored reports
+func marshalHistory(r *Report) ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if len(r.Values) > 0 {
+		if fields["values"], err = json.Marshal(r.Values); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Creates a report from JSON stored in the history along with its raw values
+func unmarshalHistory(input io.Reader) (*Report, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	report, err := LoadReportJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	stored := struct {
+		Values map[string][]string `json:"values"`
+	}{report.Values}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	if stored.Values != nil {
+		report.Values = stored.Values
+	}
+	return report, nil
+}
+
+// Removes stored reports of all hosts so that at most keep reports remain
+// for each host and none are older than maxAge. Limits that are zero are not
+// applied. Returns the amount of removed reports
+func (h *History) Prune(keep int, maxAge time.Duration,
+	now time.Time) (int, error) {
+
+	hosts, err := h.Hosts()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, host := range hosts {
+		runs, err := h.Runs(host)
+		if err != nil {
+			return removed, err
+		}
+
+		for i, run := range runs {
+			tooMany := keep > 0 && len(runs)-i > keep
+			tooOld := maxAge > 0 && now.Sub(run.Time) > maxAge
+			if !tooMany && !tooOld {
+				continue
+			}
+			if err := os.Remove(run.Path); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Returns when findings of each test of the host were first and last seen,
+// sorted by test name and type
+func (h *History) TestHistory(host string) ([]*TestHistory, error) {
+	runs, err := h.Runs(host)
+	if err != nil {
+		return nil, err
+	}
+
+	tests := make(map[string]*TestHistory)
+	for i, run := range runs {
+		report, err := h.Load(run)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range report.Tests {
+			seen := make(map[string]bool)
+			for _, f := range t.Findings() {
+				key := t.Name + "|" + f.Type
+				if seen[key] {
+					continue // count each test once per run
+				}
+				seen[key] = true
+
+				th, ok := tests[key]
+				if !ok {
+					th = &TestHistory{
+						Name:      t.Name,
+						Type:      f.Type,
+						FirstSeen: run.Time,
+					}
+					tests[key] = th
+				}
+				th.LastSeen = run.Time
+				th.Runs++
+				th.Current = i == len(runs)-1
+			}
+		}
+	}
+
+	history := make([]*TestHistory, 0, len(tests))
+	for _, th := range tests {
+		history = append(history, th)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].Name == history[j].Name {
+			return history[i].Type > history[j].Type
+		}
+		return history[i].Name < history[j].Name
+	})
+	return history, nil
+}
@@ -0,0 +1,160 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Escapes tag keys, tag values and field keys in InfluxDB line protocol
+var influxTagEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `,
+	"\n", `\n`)
+
+// Escapes string field values in InfluxDB line protocol
+var influxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// Writes a single point in InfluxDB line protocol to Writer. Tags and fields
+// are name value pairs, tags with empty values are left out. Timestamp is
+// left out if it is less than zero
+func writeInfluxPoint(w io.Writer, measurement string, tags []string,
+	fields []interface{}, timestamp int64) error {
+
+	// build the complete line before writing it
+	buf := &bytes.Buffer{}
+	buf.WriteString(measurement)
+	for i := 0; i < len(tags); i += 2 {
+		if len(tags[i+1]) < 1 {
+			continue
+		}
+		fmt.Fprintf(buf, ",%s=%s", influxTagEscaper.Replace(tags[i]),
+			influxTagEscaper.Replace(tags[i+1]))
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		if i == 0 {
+			buf.WriteRune(' ')
+		} else {
+			buf.WriteRune(',')
+		}
+		buf.WriteString(influxTagEscaper.Replace(fields[i].(string)))
+		buf.WriteRune('=')
+		switch v := fields[i+1].(type) {
+		case int:
+			buf.WriteString(strconv.Itoa(v) + "i")
+		case float64:
+			buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		case string:
+			buf.WriteString(`"` + influxStringEscaper.Replace(v) + `"`)
+		case bool:
+			buf.WriteString(strconv.FormatBool(v))
+		}
+	}
+
+	if timestamp >= 0 {
+		buf.WriteRune(' ')
+		buf.WriteString(strconv.FormatInt(timestamp, 10))
+	}
+	buf.WriteRune('\n')
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Serializes the report into InfluxDB line protocol. A lynis_summary point
+// is written for the report and a lynis_finding point for every test element.
+// Points are timestamped with the end time of the scan, findings that share
+// the same test and type are offset by a nanosecond so they are not
+// overwritten in InfluxDB. Points are written to Writer as they are created
+func (r *Report) SerializeForInflux(w io.Writer) error {
+	var timestamp int64 = -1
+	if end, err := ParseTime(r.DateTimeEnd); err == nil {
+		timestamp = end.UnixNano()
+	}
+
+	warnings, suggestions := r.CountUnsuppressed()
+	err := writeInfluxPoint(w, "lynis_summary",
+		[]string{"host", r.Hostname, "lynis_version", r.LynisVersion},
+		[]interface{}{
+			"warnings", warnings,
+			"suggestions", suggestions,
+			"hardening_index", r.HardeningIndex,
+			"duration", r.Duration().Seconds(),
+			"risk_score", r.RiskScore(),
+		}, timestamp)
+	if err != nil {
+		return err
+	}
+
+	// sort tests so output is always in the same order
+	names := r.TestNames()
+
+	for _, name := range names {
+		t := r.Tests[name]
+		for _, tees := range []struct {
+			typ      string
+			elements []*TestElement
+		}{{"warning", t.Warnings}, {"suggestion", t.Suggestions}} {
+			for i, te := range tees.elements {
+				ts := timestamp
+				if ts >= 0 {
+					ts += int64(i)
+				}
+				err := writeInfluxPoint(w, "lynis_finding",
+					[]string{
+						"host", r.Hostname,
+						"test_id", name,
+						"type", tees.typ,
+						"severity", (&Finding{t, tees.typ, te}).Severity(),
+					},
+					[]interface{}{
+						"message", te.Message,
+						"details", te.Details,
+						"solution", te.Solution,
+						"suppressed", te.Suppressed,
+					}, ts)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// OutputFormatter that will format report as InfluxDB line protocol
+type FormatInflux struct {
+	next OutputFormatter
+}
+
+// Serializes Report into InfluxDB line protocol and writes it to Writer
+func (fi *FormatInflux) Format(report *Report, w io.Writer) error {
+	// add space between data if data has already been written
+	if err := separate(w); err != nil {
+		return err
+	}
+	if err := report.SerializeForInflux(w); err != nil {
+		return err
+	}
+
+	// execute next formatter if it exists
+	return formatNext(fi, report, w)
+}
+
+// Gets next formatter
+func (fi *FormatInflux) Next() OutputFormatter {
+	return fi.next
+}
+
+// Sets next formatter
+func (fi *FormatInflux) SetNext(next OutputFormatter) {
+	fi.next = next
+}
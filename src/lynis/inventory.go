@@ -0,0 +1,161 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Inventory describes the scanned system from the details Lynis collects
+// during a scan
+type Inventory struct {
+	Hostname          string     `json:"hostname"`
+	HostID            string     `json:"hostid"`
+	Domainname        string     `json:"domainname,omitempty"`
+	OS                string     `json:"os"`
+	OSName            string     `json:"os_name,omitempty"`
+	OSVersion         string     `json:"os_version,omitempty"`
+	OSFullName        string     `json:"os_fullname,omitempty"`
+	KernelVersion     string     `json:"os_kernel_version,omitempty"`
+	KernelVersionFull string     `json:"os_kernel_version_full,omitempty"`
+	BootLoader        string     `json:"boot_loader,omitempty"`
+	CPUPAE            bool       `json:"cpu_pae"`
+	CPUNX             bool       `json:"cpu_nx"`
+	VM                bool       `json:"vm"`
+	VMType            string     `json:"vm_type,omitempty"`
+	Container         bool       `json:"container"`
+	MemorySize        int        `json:"memory_size,omitempty"`
+	MemoryUnits       string     `json:"memory_units,omitempty"`
+	UptimeDays        int        `json:"uptime_days,omitempty"`
+	IPv4Addresses     []string   `json:"ipv4_addresses"`
+	IPv6Addresses     []string   `json:"ipv6_addresses"`
+	MACAddresses      []string   `json:"mac_addresses"`
+	Interfaces        []string   `json:"interfaces"`
+	Nameservers       []string   `json:"nameservers"`
+	DefaultGateways   []string   `json:"default_gateways"`
+	RunningServices   []string   `json:"running_services"`
+	PackageManagers   []string   `json:"package_managers"`
+	Packages          []*Package `json:"packages"`
+	DateTimeEnd       string     `json:"datetime_end"`
+}
+
+// Columns of inventories serialized as CSV
+var inventoryColumns = []string{"hostname", "hostid", "domainname", "os",
+	"os_name", "os_version", "os_fullname", "os_kernel_version",
+	"os_kernel_version_full", "boot_loader", "cpu_pae", "cpu_nx", "vm",
+	"vm_type", "container", "memory_size", "memory_units", "uptime_days",
+	"ipv4_addresses", "ipv6_addresses", "mac_addresses", "interfaces",
+	"nameservers", "default_gateways", "running_services", "package_managers",
+	"packages", "datetime_end"}
+
+// Creates the inventory of the scanned system from the report
+func (r *Report) Inventory() *Inventory {
+	value := func(key string) string {
+		if values := r.Value(key); len(values) > 0 {
+			return values[len(values)-1]
+		}
+		return ""
+	}
+	flag := func(key string) bool {
+		b, _ := strconv.ParseBool(value(key))
+		return b
+	}
+	number := func(key string) int {
+		n, _ := strconv.Atoi(value(key))
+		return n
+	}
+	list := func(key string) []string {
+		return append([]string{}, r.Value(key)...)
+	}
+
+	return &Inventory{
+		Hostname:          r.Hostname,
+		HostID:            r.HostID,
+		Domainname:        value("domainname"),
+		OS:                value("os"),
+		OSName:            value("os_name"),
+		OSVersion:         value("os_version"),
+		OSFullName:        value("os_fullname"),
+		KernelVersion:     value("os_kernel_version"),
+		KernelVersionFull: value("os_kernel_version_full"),
+		BootLoader:        value("boot_loader"),
+		CPUPAE:            flag("cpu_pae"),
+		CPUNX:             flag("cpu_nx"),
+		VM:                flag("vm"),
+		VMType:            value("vmtype"),
+		Container:         flag("container"),
+		MemorySize:        number("memory_size"),
+		MemoryUnits:       value("memory_units"),
+		UptimeDays:        number("uptime_in_days"),
+		IPv4Addresses:     list("network_ipv4_address"),
+		IPv6Addresses:     list("network_ipv6_address"),
+		MACAddresses:      list("network_mac_address"),
+		Interfaces:        list("network_interface"),
+		Nameservers:       list("nameserver"),
+		DefaultGateways:   list("default_gateway"),
+		RunningServices:   list("running_service"),
+		PackageManagers:   list("package_manager"),
+		Packages:          r.Packages().Installed,
+		DateTimeEnd:       r.DateTimeEnd,
+	}
+}
+
+// Serializes the inventory as a JSON line and writes it to Writer
+func (inv *Inventory) SerializeForJSON(w io.Writer) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Serializes the inventories as CSV with a row for each inventory and writes
+// them to Writer. Lists are separated by ; and only the amount of packages is
+// written
+func SerializeInventoriesForCSV(w io.Writer, inventories []*Inventory) error {
+	cw := csv.NewWriter(w)
+	cw.Write(inventoryColumns)
+	for _, inv := range inventories {
+		cw.Write([]string{
+			inv.Hostname,
+			inv.HostID,
+			inv.Domainname,
+			inv.OS,
+			inv.OSName,
+			inv.OSVersion,
+			inv.OSFullName,
+			inv.KernelVersion,
+			inv.KernelVersionFull,
+			inv.BootLoader,
+			strconv.FormatBool(inv.CPUPAE),
+			strconv.FormatBool(inv.CPUNX),
+			strconv.FormatBool(inv.VM),
+			inv.VMType,
+			strconv.FormatBool(inv.Container),
+			strconv.Itoa(inv.MemorySize),
+			inv.MemoryUnits,
+			strconv.Itoa(inv.UptimeDays),
+			strings.Join(inv.IPv4Addresses, ";"),
+			strings.Join(inv.IPv6Addresses, ";"),
+			strings.Join(inv.MACAddresses, ";"),
+			strings.Join(inv.Interfaces, ";"),
+			strings.Join(inv.Nameservers, ";"),
+			strings.Join(inv.DefaultGateways, ";"),
+			strings.Join(inv.RunningServices, ";"),
+			strings.Join(inv.PackageManagers, ";"),
+			strconv.Itoa(len(inv.Packages)),
+			inv.DateTimeEnd,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
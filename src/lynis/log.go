@@ -0,0 +1,189 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Regex format strings to find lines in the Lynis log
+const (
+	// Line of the log starting with the time it was written
+	LOG_LINE_REG string = `^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) (.*)$`
+
+	// Test that was started
+	LOG_PERFORMING_REG string = `^Performing test ID (\S+)(?: \((.*)\))?`
+
+	// Test that was skipped
+	LOG_SKIPPED_REG string = `^Skipped test (\S+)(?: \((.*)\))?`
+
+	// Reason the previous test was skipped
+	LOG_SKIP_REASON_REG string = `^Reason to skip: (.*)`
+
+	// Separator written before each test and section
+	LOG_SEPARATOR_REG string = `^===-+===`
+
+	// Result of a test
+	LOG_RESULT_REG string = `^Result: (.*)`
+)
+
+// Status of tests in the Lynis log
+const (
+	TEST_PERFORMED string = "performed"
+	TEST_SKIPPED   string = "skipped"
+)
+
+var (
+	logLine       = regexp.MustCompile(LOG_LINE_REG)
+	logPerforming = regexp.MustCompile(LOG_PERFORMING_REG)
+	logSkipped    = regexp.MustCompile(LOG_SKIPPED_REG)
+	logSkipReason = regexp.MustCompile(LOG_SKIP_REASON_REG)
+	logSeparator  = regexp.MustCompile(LOG_SEPARATOR_REG)
+	logResult     = regexp.MustCompile(LOG_RESULT_REG)
+)
+
+// TestExecution describes how a test was executed during the scan
+type TestExecution struct {
+	Test        string   `json:"test"`
+	Description string   `json:"description,omitempty"`
+	Status      string   `json:"status"`
+	Reason      string   `json:"reason,omitempty"`
+	Start       string   `json:"start"`
+	Duration    float64  `json:"duration_seconds"`
+	Results     []string `json:"results,omitempty"`
+}
+
+// Log of a Lynis scan with the execution of each test by test ID
+type Log struct {
+	Tests map[string]*TestExecution
+}
+
+// Parses the Lynis log from Reader. Tests are performed from the line they
+// are started until the next separator or test, durations have a resolution
+// of seconds
+func ParseLog(input io.Reader) (*Log, error) {
+	l := &Log{Tests: make(map[string]*TestExecution)}
+
+	var current *TestExecution // test being performed
+	var skipped *TestExecution // last test skipped
+	var start time.Time        // start of test being performed
+	var last time.Time         // time of last line
+	end := func() {
+		if current != nil {
+			current.Duration += last.Sub(start).Seconds()
+			current = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		match := logLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue // continuation of previous line
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", match[1],
+			time.Local)
+		if err != nil {
+			continue
+		}
+		last = t
+		text := strings.TrimSpace(match[2])
+
+		if m := logPerforming.FindStringSubmatch(text); m != nil {
+			end()
+			current = l.test(m[1], m[2], TEST_PERFORMED, t)
+			start = t
+			skipped = nil
+		} else if m := logSkipped.FindStringSubmatch(text); m != nil {
+			end()
+			skipped = l.test(m[1], m[2], TEST_SKIPPED, t)
+		} else if m := logSkipReason.FindStringSubmatch(text); m != nil {
+			if skipped != nil {
+				skipped.Reason = strings.TrimSpace(m[1])
+			}
+		} else if logSeparator.MatchString(text) {
+			end()
+			skipped = nil
+		} else if m := logResult.FindStringSubmatch(text); m != nil {
+			if current != nil {
+				current.Results = append(current.Results,
+					strings.TrimSpace(m[1]))
+			}
+		}
+	}
+	end()
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reads the Lynis log file and parses it
+func ReadLogFile(path string) (*Log, error) {
+	input, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	return ParseLog(input)
+}
+
+// Returns the execution of the test, tests that are performed after being
+// skipped are performed
+func (l *Log) test(id, description, status string,
+	t time.Time) *TestExecution {
+
+	te, ok := l.Tests[id]
+	if !ok {
+		te = &TestExecution{Test: id}
+		te.Start, _ = FormatTime(t.Format("2006-01-02 15:04:05"))
+		l.Tests[id] = te
+	}
+	if len(description) > 0 {
+		te.Description = description
+	}
+	if te.Status != TEST_PERFORMED {
+		te.Status = status
+	}
+	return te
+}
+
+// Adds the execution of tests from the log to the report. Tests of the report
+// are linked to their execution
+func (r *Report) AddLog(l *Log) {
+	if r.Executions == nil {
+		r.Executions = make(map[string]*TestExecution)
+	}
+	for id, te := range l.Tests {
+		r.Executions[id] = te
+	}
+	for id, t := range r.Tests {
+		t.Execution = r.Executions[id]
+	}
+}
+
+// Returns the amount of tests that were performed and skipped according to
+// the log added to the report
+func (r *Report) CountExecutions() (performed int, skipped int) {
+	for _, te := range r.Executions {
+		switch te.Status {
+		case TEST_PERFORMED:
+			performed++
+		case TEST_SKIPPED:
+			skipped++
+		}
+	}
+	return
+}
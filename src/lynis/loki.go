@@ -0,0 +1,198 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Path of the Loki push API
+const (
+	LOKI_PUSH_PATH string = "/loki/api/v1/push"
+)
+
+// LokiPush is the body of a request sent to the Loki push API
+type LokiPush struct {
+	Streams []*LokiStream `json:"streams"`
+}
+
+// LokiStream is a set of log lines that share the same labels. Values are
+// pairs of nanosecond timestamp and log line
+type LokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// LokiLine is the log line sent to Loki for each test element, fields with
+// high cardinality are kept in the line instead of the stream labels
+type LokiLine struct {
+	Test       string `json:"test"`
+	Message    string `json:"message"`
+	Details    string `json:"details"`
+	Solution   string `json:"solution"`
+	Severity   string `json:"severity"`
+	Suppressed bool   `json:"suppressed,omitempty"`
+}
+
+// Creates the Loki push body from the report. Test elements are grouped into
+// streams labeled by host, lynis version, type and test category
+func (r *Report) CreateLokiPush() (*LokiPush, error) {
+	// timestamp all lines with the end time of the scan
+	timestamp := time.Now()
+	if len(r.DateTimeEnd) > 0 {
+		var err error
+		timestamp, err = ParseTime(r.DateTimeEnd)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ts := strconv.FormatInt(timestamp.UnixNano(), 10)
+
+	tees, _ := r.CreateTestElementElastics()
+
+	// sort elements so streams and lines are always in the same order
+	sort.SliceStable(tees, func(i, j int) bool {
+		return tees[i].Name < tees[j].Name
+	})
+
+	push := &LokiPush{Streams: make([]*LokiStream, 0)}
+	streams := make(map[string]*LokiStream)
+	for _, te := range tees {
+		// values of the report that do not belong to a test are identified
+		// by their type and have no category
+		category := ""
+		if catalogueTestID.MatchString(te.Name) {
+			category = TestCategory(te.Name)
+		}
+
+		// find stream with matching labels or create it
+		id := te.Type + "|" + category
+		stream, ok := streams[id]
+		if !ok {
+			stream = &LokiStream{
+				Stream: map[string]string{
+					"job":           "lynis",
+					"host":          r.Hostname,
+					"lynis_version": r.LynisVersion,
+					"type":          te.Type,
+					"category":      category,
+				},
+				Values: make([][2]string, 0),
+			}
+			// Loki does not accept labels with empty values
+			for k, v := range stream.Stream {
+				if len(v) < 1 {
+					delete(stream.Stream, k)
+				}
+			}
+			streams[id] = stream
+			push.Streams = append(push.Streams, stream)
+		}
+
+		line, err := json.Marshal(&LokiLine{
+			Test:       te.Name,
+			Message:    te.Message,
+			Details:    te.Details,
+			Solution:   te.Solution,
+			Severity:   te.Severity,
+			Suppressed: te.Suppressed,
+		})
+		if err != nil {
+			return nil, err
+		}
+		stream.Values = append(stream.Values, [2]string{ts, string(line)})
+	}
+
+	return push, nil
+}
+
+// OutputFormatter that will format report as the JSON body of a Loki push
+// request
+type FormatLoki struct {
+	next OutputFormatter
+}
+
+// Serializes Report into a Loki push request body and writes it to Writer
+func (fl *FormatLoki) Format(report *Report, w io.Writer) error {
+	push, err := report.CreateLokiPush()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(push)
+	if err != nil {
+		return err
+	}
+
+	// add space between data if data has already been written
+	if err := separate(w); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	// execute next formatter if it exists
+	return formatNext(fl, report, w)
+}
+
+// Gets next formatter
+func (fl *FormatLoki) Next() OutputFormatter {
+	return fl.next
+}
+
+// Sets next formatter
+func (fl *FormatLoki) SetNext(next OutputFormatter) {
+	fl.next = next
+}
+
+// LokiClient pushes data formatted by FormatLoki to a Loki server
+type LokiClient struct {
+	URL    string       // base URL of Loki server eg. http://localhost:3100
+	Tenant string       // optional tenant sent in X-Scope-OrgID header
+	Client *http.Client // client used for requests, http.DefaultClient if nil
+}
+
+// Pushes the serialized data to the Loki push API
+func (lc *LokiClient) Push(data []byte) error {
+	client := lc.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodPost, lc.URL+LOKI_PUSH_PATH,
+		bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(lc.Tenant) > 0 {
+		req.Header.Set("X-Scope-OrgID", lc.Tenant)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Loki responds with 204 on success
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New(fmt.Sprintf("loki push failed with status %s: %s",
+			resp.Status, bytes.TrimSpace(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Values of the OCSF Compliance Finding class
+const (
+	OCSF_VERSION           string = "1.1.0"
+	OCSF_CATEGORY_FINDINGS int    = 2
+	OCSF_CLASS_COMPLIANCE  int    = 2003
+	OCSF_ACTIVITY_CREATE   int    = 1
+	OCSF_STATUS_NEW        int    = 1
+	OCSF_STATUS_SUPPRESSED int    = 3
+	OCSF_COMPLIANCE_FAIL   int    = 3
+	OCSF_SEVERITY_INFO     int    = 1
+	OCSF_SEVERITY_LOW      int    = 2
+	OCSF_SEVERITY_MEDIUM   int    = 3
+	OCSF_SEVERITY_HIGH     int    = 4
+	OCSF_SEVERITY_CRITICAL int    = 5
+)
+
+// OCSFComplianceFinding is a test element mapped to the OCSF Compliance
+// Finding class
+type OCSFComplianceFinding struct {
+	ActivityID  int              `json:"activity_id"`
+	CategoryUID int              `json:"category_uid"`
+	ClassUID    int              `json:"class_uid"`
+	TypeUID     int              `json:"type_uid"`
+	SeverityID  int              `json:"severity_id"`
+	Severity    string           `json:"severity"`
+	StatusID    int              `json:"status_id"`
+	Time        int64            `json:"time"`
+	Message     string           `json:"message"`
+	FindingInfo OCSFFindingInfo  `json:"finding_info"`
+	Compliance  OCSFCompliance   `json:"compliance"`
+	Remediation *OCSFRemediation `json:"remediation,omitempty"`
+	Device      OCSFDevice       `json:"device"`
+	Metadata    OCSFMetadata     `json:"metadata"`
+}
+
+// OCSFFindingInfo describes the finding
+type OCSFFindingInfo struct {
+	UID   string   `json:"uid"`
+	Title string   `json:"title"`
+	Desc  string   `json:"desc,omitempty"`
+	Types []string `json:"types"`
+}
+
+// OCSFCompliance describes the control that failed
+type OCSFCompliance struct {
+	Control      string   `json:"control"`
+	Requirements []string `json:"requirements,omitempty"`
+	Standards    []string `json:"standards"`
+	Status       string   `json:"status"`
+	StatusID     int      `json:"status_id"`
+}
+
+// OCSFRemediation describes how to remediate the finding
+type OCSFRemediation struct {
+	Desc string `json:"desc"`
+}
+
+// OCSFDevice describes the scanned system
+type OCSFDevice struct {
+	Hostname string `json:"hostname,omitempty"`
+	UID      string `json:"uid,omitempty"`
+	TypeID   int    `json:"type_id"`
+}
+
+// OCSFMetadata describes the product that generated the finding
+type OCSFMetadata struct {
+	Version string      `json:"version"`
+	Product OCSFProduct `json:"product"`
+}
+
+// OCSFProduct describes Lynis
+type OCSFProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+	Version    string `json:"version"`
+}
+
+// Returns the value of a test element field, Lynis uses - for empty fields
+func ocsfValue(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// Creates an OCSF Compliance Finding from a flattened test element
+func CreateOCSFComplianceFinding(r *Report,
+	tee *TestElementElastic) *OCSFComplianceFinding {
+
+	// map severity of finding to OCSF severity
+	severityID, severity := OCSF_SEVERITY_INFO, "Informational"
+	switch tee.Severity {
+	case SEVERITY_LOW:
+		severityID, severity = OCSF_SEVERITY_LOW, "Low"
+	case SEVERITY_MEDIUM:
+		severityID, severity = OCSF_SEVERITY_MEDIUM, "Medium"
+	case SEVERITY_HIGH:
+		severityID, severity = OCSF_SEVERITY_HIGH, "High"
+	case SEVERITY_CRITICAL:
+		severityID, severity = OCSF_SEVERITY_CRITICAL, "Critical"
+	}
+
+	timestamp := time.Now()
+	if end, err := ParseTime(r.DateTimeEnd); err == nil {
+		timestamp = end
+	}
+
+	finding := &OCSFComplianceFinding{
+		ActivityID:  OCSF_ACTIVITY_CREATE,
+		CategoryUID: OCSF_CATEGORY_FINDINGS,
+		ClassUID:    OCSF_CLASS_COMPLIANCE,
+		TypeUID:     OCSF_CLASS_COMPLIANCE*100 + OCSF_ACTIVITY_CREATE,
+		SeverityID:  severityID,
+		Severity:    severity,
+		StatusID:    OCSF_STATUS_NEW,
+		Time:        timestamp.UnixMilli(),
+		Message:     tee.Message,
+		FindingInfo: OCSFFindingInfo{
+			UID:   tee.Name,
+			Title: tee.Message,
+			Desc:  ocsfValue(tee.Details),
+			Types: []string{tee.Type},
+		},
+		Compliance: OCSFCompliance{
+			Control:      tee.Name,
+			Requirements: tee.Controls,
+			Standards:    []string{"Lynis"},
+			Status:       "Fail",
+			StatusID:     OCSF_COMPLIANCE_FAIL,
+		},
+		Device: OCSFDevice{
+			Hostname: r.Hostname,
+			UID:      r.HostID,
+		},
+		Metadata: OCSFMetadata{
+			Version: OCSF_VERSION,
+			Product: OCSFProduct{
+				Name:       "Lynis",
+				VendorName: "CISOfy",
+				Version:    r.LynisVersion,
+			},
+		},
+	}
+
+	if tee.Suppressed {
+		finding.StatusID = OCSF_STATUS_SUPPRESSED
+	}
+
+	if solution := ocsfValue(tee.Solution); len(solution) > 0 {
+		finding.Remediation = &OCSFRemediation{Desc: solution}
+	}
+
+	return finding
+}
+
+// Serializes every warning, suggestion and manual check of the report as an
+// OCSF Compliance Finding seperated by new lines and writes them to Writer.
+// Details and other values of the report are not findings and are skipped
+func (r *Report) SerializeForOCSF(w io.Writer) error {
+	tees, _ := r.CreateTestElementElastics()
+
+	for _, tee := range tees {
+		switch tee.Type {
+		case "warning", "suggestion", "manual":
+		default:
+			continue
+		}
+
+		finding, err := json.Marshal(CreateOCSFComplianceFinding(r, tee))
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(append(finding, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OutputFormatter that will format report as OCSF Compliance Findings
+// seperated by new lines
+type FormatOCSF struct {
+	next OutputFormatter
+}
+
+// Serializes Report into OCSF Compliance Findings and writes them to Writer
+func (fo *FormatOCSF) Format(report *Report, w io.Writer) error {
+	// add space between data if data has already been written
+	if err := separate(w); err != nil {
+		return err
+	}
+
+	if err := report.SerializeForOCSF(w); err != nil {
+		return err
+	}
+
+	// execute next formatter if it exists
+	return formatNext(fo, report, w)
+}
+
+// Gets next formatter
+func (fo *FormatOCSF) Next() OutputFormatter {
+	return fo.next
+}
+
+// Sets next formatter
+func (fo *FormatOCSF) SetNext(next OutputFormatter) {
+	fo.next = next
+}
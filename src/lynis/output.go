@@ -0,0 +1,124 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PendingFile streams output to a temporary file and only writes it to its
+// destination once the output is complete and committed, so the destination
+// is never left with partial output when an error occurs. Output is either
+// appended to the destination or replaces it atomically
+type PendingFile struct {
+	path       string      // destination of output
+	appendMode bool        // append to destination instead of replacing it
+	perm       os.FileMode // permissions of destination
+	tmp        *os.File    // temporary file output is streamed to
+	dest       *os.File    // destination opened for appending
+}
+
+// Creates a PendingFile for the destination path. Destinations that are
+// appended to are opened straight away so errors are returned before any
+// output is written. Temporary files for replaced destinations are created in
+// the same directory so they can be renamed over the destination
+func CreatePendingFile(path string, appendMode bool,
+	perm os.FileMode) (*PendingFile, error) {
+
+	pf := &PendingFile{
+		path:       path,
+		appendMode: appendMode,
+		perm:       perm,
+	}
+
+	dir := filepath.Dir(path)
+	if appendMode {
+		var err error
+		pf.dest, err = os.OpenFile(path,
+			os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+		if err != nil {
+			return nil, err
+		}
+		dir = os.TempDir()
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		if pf.dest != nil {
+			pf.dest.Close()
+		}
+		return nil, err
+	}
+	pf.tmp = tmp
+
+	return pf, nil
+}
+
+// Writes output to the temporary file
+func (pf *PendingFile) Write(p []byte) (int, error) {
+	return pf.tmp.Write(p)
+}
+
+// Writes the output to the destination and removes the temporary file
+func (pf *PendingFile) Commit() error {
+	defer pf.Abort()
+
+	if !pf.appendMode {
+		// replace destination with temporary file
+		if err := pf.tmp.Chmod(pf.perm); err != nil {
+			return err
+		}
+		if err := pf.tmp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(pf.tmp.Name(), pf.path)
+	}
+
+	// append temporary file to destination
+	if _, err := pf.tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(pf.dest, pf.tmp); err != nil {
+		return err
+	}
+	err := pf.dest.Close()
+	pf.dest = nil
+	return err
+}
+
+// Discards the output and removes the temporary file
+func (pf *PendingFile) Abort() error {
+	if pf.dest != nil {
+		pf.dest.Close()
+		pf.dest = nil
+	}
+	pf.tmp.Close()
+	err := os.Remove(pf.tmp.Name())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Writes data to file by writing to a temporary file in the same directory
+// and renaming it, so readers such as the textfile collector never see a
+// partially written file
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	pf, err := CreatePendingFile(path, false, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := pf.Write(data); err != nil {
+		pf.Abort()
+		return err
+	}
+
+	return pf.Commit()
+}
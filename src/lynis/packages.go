@@ -0,0 +1,299 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	CYCLONEDX_SPEC_VERSION string = "1.5"
+)
+
+// Package is a software package installed on the scanned system
+type Package struct {
+	Name       string `json:"name"`
+	Version    string `json:"version,omitempty"`
+	Vulnerable bool   `json:"vulnerable,omitempty"`
+}
+
+// PackageReport lists the installed packages of a host and the packages Lynis
+// found to be vulnerable
+type PackageReport struct {
+	Hostname        string     `json:"hostname"`
+	HostID          string     `json:"hostid"`
+	OSName          string     `json:"os_name,omitempty"`
+	OSVersion       string     `json:"os_version,omitempty"`
+	PackageManagers []string   `json:"package_managers"`
+	VulnerableFound bool       `json:"vulnerable_packages_found"`
+	Installed       []*Package `json:"installed"`
+	Vulnerable      []*Package `json:"vulnerable"`
+	DateTimeEnd     string     `json:"datetime_end"`
+}
+
+// Returns the installed and vulnerable packages of the report. Installed
+// packages that are vulnerable are marked and vulnerable packages without a
+// version are given the installed version
+func (r *Report) Packages() *PackageReport {
+	last := func(key string) string {
+		if values := r.Value(key); len(values) > 0 {
+			return values[len(values)-1]
+		}
+		return ""
+	}
+
+	pr := &PackageReport{
+		Hostname:        r.Hostname,
+		HostID:          r.HostID,
+		OSName:          last("os_name"),
+		OSVersion:       last("os_version"),
+		PackageManagers: append([]string{}, r.Value("package_manager")...),
+		Installed:       parsePackages(last("installed_packages_array")),
+		Vulnerable:      make([]*Package, 0),
+		DateTimeEnd:     r.DateTimeEnd,
+	}
+	pr.VulnerableFound, _ = strconv.ParseBool(last("vulnerable_packages_found"))
+
+	installed := make(map[string]*Package, len(pr.Installed))
+	for _, p := range pr.Installed {
+		installed[p.Name] = p
+	}
+	for _, value := range r.Value("vulnerable_package") {
+		p := parsePackage(value)
+		p.Vulnerable = true
+		if i, ok := installed[p.Name]; ok {
+			i.Vulnerable = true
+			if len(p.Version) < 1 {
+				p.Version = i.Version
+			}
+		}
+		pr.Vulnerable = append(pr.Vulnerable, p)
+	}
+	if len(pr.Vulnerable) > 0 {
+		pr.VulnerableFound = true
+	}
+	return pr
+}
+
+// Parses the installed packages of a report which are separated by | with
+// the name and version of each package separated by a comma, eg.
+//
+//	|adduser,3.118|apt,2.2.4|
+func parsePackages(value string) []*Package {
+	packages := make([]*Package, 0)
+	for _, p := range parseList(value) {
+		packages = append(packages, parsePackage(p))
+	}
+	return packages
+}
+
+// Parses a package with the name and optional version separated by a comma
+func parsePackage(value string) *Package {
+	name, version, _ := strings.Cut(value, ",")
+	return &Package{
+		Name:    strings.TrimSpace(name),
+		Version: strings.TrimSpace(version),
+	}
+}
+
+// Serializes the package report to JSON and writes it to Writer
+func (pr *PackageReport) SerializeForJSON(w io.Writer) error {
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Serializes the package report as a table of vulnerable packages followed by
+// the installed packages and writes it to Writer
+func (pr *PackageReport) SerializeForText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Host:       %s\n", pr.Hostname)
+	fmt.Fprintf(tw, "Installed:  %d\n", len(pr.Installed))
+	fmt.Fprintf(tw, "Vulnerable: %d\n", len(pr.Vulnerable))
+	if len(pr.Vulnerable) > 0 {
+		fmt.Fprintln(tw, "")
+		fmt.Fprintln(tw, "VULNERABLE PACKAGE\tVERSION")
+		for _, p := range pr.Vulnerable {
+			fmt.Fprintf(tw, "%s\t%s\n", p.Name, versionOrDash(p.Version))
+		}
+	}
+	if len(pr.Installed) > 0 {
+		fmt.Fprintln(tw, "")
+		fmt.Fprintln(tw, "PACKAGE\tVERSION\tVULNERABLE")
+		for _, p := range pr.Installed {
+			fmt.Fprintf(tw, "%s\t%s\t%t\n", p.Name, versionOrDash(p.Version),
+				p.Vulnerable)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// Returns version or - when there is none
+func versionOrDash(version string) string {
+	if len(version) < 1 {
+		return "-"
+	}
+	return version
+}
+
+// CycloneDX software bill of materials
+type CycloneDX struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     CycloneDXMetadata     `json:"metadata"`
+	Components   []*CycloneDXComponent `json:"components"`
+}
+
+// CycloneDX metadata describing the host the packages are installed on
+type CycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp,omitempty"`
+	Tools     CycloneDXTools     `json:"tools"`
+	Component CycloneDXComponent `json:"component"`
+}
+
+// CycloneDX tools that created the bill of materials
+type CycloneDXTools struct {
+	Components []*CycloneDXComponent `json:"components"`
+}
+
+// CycloneDX component
+type CycloneDXComponent struct {
+	Type       string               `json:"type"`
+	BOMRef     string               `json:"bom-ref,omitempty"`
+	Name       string               `json:"name"`
+	Version    string               `json:"version,omitempty"`
+	PURL       string               `json:"purl,omitempty"`
+	Properties []*CycloneDXProperty `json:"properties,omitempty"`
+}
+
+// CycloneDX name and value property
+type CycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Creates a CycloneDX bill of materials of the installed packages. The host
+// is the component the bill of materials describes and is referenced by its
+// host ID, the serial number is derived from the host ID and end of the scan
+// so the same report always has the same serial number
+func (pr *PackageReport) CycloneDX() *CycloneDX {
+	sum := sha1.Sum([]byte(pr.HostID + "/" + pr.DateTimeEnd))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	serial := fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x",
+		sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+
+	bom := &CycloneDX{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  CYCLONEDX_SPEC_VERSION,
+		SerialNumber: serial,
+		Version:      1,
+		Metadata: CycloneDXMetadata{
+			Tools: CycloneDXTools{
+				Components: []*CycloneDXComponent{{
+					Type: "application",
+					Name: "lynisreport",
+				}},
+			},
+			Component: CycloneDXComponent{
+				Type:    "operating-system",
+				BOMRef:  pr.HostID,
+				Name:    pr.Hostname,
+				Version: pr.OSVersion,
+				Properties: []*CycloneDXProperty{
+					{Name: "lynis:hostid", Value: pr.HostID},
+					{Name: "lynis:os_name", Value: pr.OSName},
+				},
+			},
+		},
+		Components: make([]*CycloneDXComponent, 0, len(pr.Installed)),
+	}
+	if end, err := ParseTime(pr.DateTimeEnd); err == nil {
+		bom.Metadata.Timestamp = end.UTC().Format(time.RFC3339)
+	}
+
+	for _, p := range pr.Installed {
+		c := &CycloneDXComponent{
+			Type:    "application",
+			BOMRef:  pr.HostID + "/" + p.Name + "@" + p.Version,
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    pr.purl(p),
+		}
+		if p.Vulnerable {
+			c.Properties = []*CycloneDXProperty{
+				{Name: "lynis:vulnerable", Value: "true"},
+			}
+		}
+		bom.Components = append(bom.Components, c)
+	}
+	return bom
+}
+
+// Returns the package URL of a package when the package manager of the host
+// is known, otherwise an empty string
+func (pr *PackageReport) purl(p *Package) string {
+	var typ string
+	for _, pm := range pr.PackageManagers {
+		switch pm {
+		case "dpkg", "apt":
+			typ = "deb"
+		case "rpm", "yum", "dnf", "zypper":
+			typ = "rpm"
+		case "apk":
+			typ = "apk"
+		case "pacman":
+			typ = "alpm"
+		}
+		if len(typ) > 0 {
+			break
+		}
+	}
+	if len(typ) < 1 || len(pr.OSName) < 1 {
+		return ""
+	}
+
+	purl := fmt.Sprintf("pkg:%s/%s/%s", typ,
+		strings.ToLower(strings.Fields(pr.OSName)[0]), purlEscape(p.Name))
+	if len(p.Version) > 0 {
+		purl += "@" + purlEscape(p.Version)
+	}
+	return purl
+}
+
+// Percent-encodes a component of a package URL, : and + are encoded as well
+// eg. 1:2.3+dfsg-1 is encoded as 1%3A2.3%2Bdfsg-1
+func purlEscape(s string) string {
+	return strings.NewReplacer(":", "%3A", "+", "%2B").Replace(
+		url.PathEscape(s))
+}
+
+// Serializes the installed packages as a CycloneDX bill of materials and
+// writes it to Writer
+func (pr *PackageReport) SerializeForCycloneDX(w io.Writer) error {
+	data, err := json.MarshalIndent(pr.CycloneDX(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,145 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// Policy is a set of thresholds a report must stay within, such as for a
+// golden image build. Suppressed findings are not counted
+type Policy struct {
+	FailOn            []string // types or least severities of findings that are not allowed
+	FailOnTests       []string // test IDs or glob patterns not allowed to have findings
+	MaxWarnings       int      // maximum amount of warnings, unlimited if negative
+	MaxSuggestions    int      // maximum amount of suggestions, unlimited if negative
+	MinHardeningIndex int      // minimum hardening index, not checked if zero
+	MaxRiskScore      int      // maximum risk score, unlimited if negative
+}
+
+// Violation is a rule of a policy that a report does not comply with
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Creates a policy without any thresholds
+func NewPolicy() *Policy {
+	return &Policy{
+		MaxWarnings:    -1,
+		MaxSuggestions: -1,
+		MaxRiskScore:   -1,
+	}
+}
+
+// Returns true if the policy has any thresholds
+func (p *Policy) Enabled() bool {
+	return len(p.FailOn) > 0 || len(p.FailOnTests) > 0 ||
+		p.MaxWarnings >= 0 || p.MaxSuggestions >= 0 ||
+		p.MinHardeningIndex > 0 || p.MaxRiskScore >= 0
+}
+
+// Checks the policy is valid
+func (p *Policy) Validate() error {
+	for _, typ := range p.FailOn {
+		if typ != "warning" && typ != "suggestion" &&
+			SeverityRank(typ) < 0 {
+			return errors.New(fmt.Sprintf(
+				"invalid finding type or severity %s", typ))
+		}
+	}
+	for _, pattern := range p.FailOnTests {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.New(fmt.Sprintf("invalid test pattern %s", pattern))
+		}
+	}
+	return nil
+}
+
+// Evaluates the report against the policy and returns the violations
+func (p *Policy) Evaluate(r *Report) []*Violation {
+	violations := make([]*Violation, 0)
+
+	// count findings that are not suppressed
+	counts := make(map[string]int)
+	failedTests := make([]string, 0)
+	for _, name := range r.TestNames() {
+		failed := false
+		for _, f := range r.Tests[name].Findings() {
+			if f.Element.Suppressed {
+				continue
+			}
+			counts[f.Type]++
+			// findings count towards their severity and every lesser one
+			for i := SeverityRank(f.Severity()); i >= 0; i-- {
+				counts[severities[i]]++
+			}
+			for _, pattern := range p.FailOnTests {
+				if match, _ := path.Match(pattern, name); match {
+					failed = true
+				}
+			}
+		}
+		if failed {
+			failedTests = append(failedTests, name)
+		}
+	}
+
+	for _, typ := range p.FailOn {
+		if counts[typ] < 1 {
+			continue
+		}
+		message := fmt.Sprintf("report has %d %ss", counts[typ], typ)
+		if SeverityRank(typ) >= 0 {
+			message = fmt.Sprintf("report has %d findings of severity %s or more severe",
+				counts[typ], typ)
+		}
+		violations = append(violations, &Violation{
+			Rule:    "fail-on " + typ,
+			Message: message,
+		})
+	}
+	if p.MaxWarnings >= 0 && counts["warning"] > p.MaxWarnings {
+		violations = append(violations, &Violation{
+			Rule: fmt.Sprintf("max-warnings %d", p.MaxWarnings),
+			Message: fmt.Sprintf("report has %d warnings",
+				counts["warning"]),
+		})
+	}
+	if p.MaxSuggestions >= 0 && counts["suggestion"] > p.MaxSuggestions {
+		violations = append(violations, &Violation{
+			Rule: fmt.Sprintf("max-suggestions %d", p.MaxSuggestions),
+			Message: fmt.Sprintf("report has %d suggestions",
+				counts["suggestion"]),
+		})
+	}
+	if p.MinHardeningIndex > 0 && r.HardeningIndex < p.MinHardeningIndex {
+		violations = append(violations, &Violation{
+			Rule: fmt.Sprintf("min-hardening-index %d", p.MinHardeningIndex),
+			Message: fmt.Sprintf("report has hardening index %d",
+				r.HardeningIndex),
+		})
+	}
+	if p.MaxRiskScore >= 0 {
+		if score := r.RiskScore(); score > p.MaxRiskScore {
+			violations = append(violations, &Violation{
+				Rule:    fmt.Sprintf("max-risk-score %d", p.MaxRiskScore),
+				Message: fmt.Sprintf("report has risk score %d", score),
+			})
+		}
+	}
+	for _, name := range failedTests {
+		violations = append(violations, &Violation{
+			Rule:    "fail-on-test " + name,
+			Message: fmt.Sprintf("report has findings for test %s", name),
+		})
+	}
+
+	return violations
+}
@@ -0,0 +1,184 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Prometheus metric family with the samples that belong to it
+type promMetric struct {
+	name    string
+	help    string
+	typ     string
+	samples []promSample
+}
+
+// Single sample of a Prometheus metric, labels are stored as name value pairs
+type promSample struct {
+	labels []string
+	value  interface{}
+}
+
+// Writes metric families to buffer in the Prometheus text exposition format,
+// or the OpenMetrics format if openMetrics is set
+func writeMetrics(buf *bytes.Buffer, metrics []*promMetric, openMetrics bool) {
+	for _, m := range metrics {
+		name := m.name
+		suffix := ""
+		// OpenMetrics counter families are named without the _total suffix
+		if openMetrics && m.typ == "counter" {
+			name = strings.TrimSuffix(m.name, "_total")
+			suffix = "_total"
+		}
+
+		fmt.Fprintf(buf, "# HELP %s %s\n", name, m.help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", name, m.typ)
+		for _, s := range m.samples {
+			buf.WriteString(name + suffix)
+			if len(s.labels) > 0 {
+				buf.WriteRune('{')
+				for i := 0; i < len(s.labels); i += 2 {
+					if i > 0 {
+						buf.WriteRune(',')
+					}
+					fmt.Fprintf(buf, "%s=\"%s\"", s.labels[i],
+						escapeLabel(s.labels[i+1]))
+				}
+				buf.WriteRune('}')
+			}
+			fmt.Fprintf(buf, " %v\n", s.value)
+		}
+	}
+	if openMetrics {
+		buf.WriteString("# EOF\n")
+	}
+}
+
+// Escapes a Prometheus label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).
+		Replace(value)
+}
+
+// Creates the Prometheus metric families describing the report
+func (r *Report) prometheusMetrics() []*promMetric {
+	var last int64
+	if end, err := ParseTime(r.DateTimeEnd); err == nil {
+		last = end.Unix()
+	}
+
+	// sort tests so output is always in the same order
+	names := r.TestNames()
+
+	// suppressed findings are not counted for tests or totals
+	warnings, suggestions := r.CountUnsuppressed()
+	suppressed := 0
+	findings := make([]promSample, 0)
+	for _, name := range names {
+		t := r.Tests[name]
+		for _, tees := range []struct {
+			typ      string
+			elements []*TestElement
+		}{{"warning", t.Warnings}, {"suggestion", t.Suggestions}} {
+			count := 0
+			for _, te := range tees.elements {
+				if te.Suppressed {
+					suppressed++
+				} else {
+					count++
+				}
+			}
+			if count > 0 {
+				findings = append(findings, promSample{
+					labels: []string{"test_id", name, "type", tees.typ},
+					value:  count,
+				})
+			}
+		}
+	}
+
+	// findings of each severity from least to most severe
+	counts := r.CountSeverities()
+	severityFindings := make([]promSample, 0, len(severities))
+	for _, s := range severities {
+		severityFindings = append(severityFindings, promSample{
+			labels: []string{"severity", s},
+			value:  counts[s],
+		})
+	}
+
+	return []*promMetric{
+		{"lynis_warnings_total", "Number of warnings found by Lynis",
+			"gauge", []promSample{{value: warnings}}},
+		{"lynis_suggestions_total", "Number of suggestions found by Lynis",
+			"gauge", []promSample{{value: suggestions}}},
+		{"lynis_hardening_index", "Hardening index calculated by Lynis",
+			"gauge", []promSample{{value: r.HardeningIndex}}},
+		{"lynis_scan_duration_seconds", "Duration of the Lynis scan",
+			"gauge", []promSample{{value: r.Duration().Seconds()}}},
+		{"lynis_last_scan_timestamp_seconds",
+			"Time the Lynis scan finished as seconds since epoch",
+			"gauge", []promSample{{value: last}}},
+		{"lynis_finding", "Number of findings of a type for a Lynis test",
+			"gauge", findings},
+		{"lynis_suppressed_findings_total",
+			"Number of findings that are suppressed as accepted risks",
+			"gauge", []promSample{{value: suppressed}}},
+		{"lynis_risk_score",
+			"Sum of the scores of the severities of findings",
+			"gauge", []promSample{{value: r.RiskScore()}}},
+		{"lynis_severity_findings",
+			"Number of findings of a severity that are not suppressed",
+			"gauge", severityFindings},
+		{"lynis_version_info", "Version of Lynis that generated the report",
+			"gauge", []promSample{{
+				labels: []string{"version", r.LynisVersion},
+				value:  1,
+			}}},
+	}
+}
+
+// Serializes the report into Prometheus text exposition format
+func (r *Report) SerializeForPrometheus() []byte {
+	buf := &bytes.Buffer{}
+	writeMetrics(buf, r.prometheusMetrics(), false)
+	return buf.Bytes()
+}
+
+// OutputFormatter that will format report as Prometheus metrics that can be
+// read by the node_exporter textfile collector
+type FormatPrometheus struct {
+	next OutputFormatter
+}
+
+// Serializes Report into Prometheus metrics and writes them to Writer
+func (fp *FormatPrometheus) Format(report *Report, w io.Writer) error {
+	// add space between data if data has already been written
+	if err := separate(w); err != nil {
+		return err
+	}
+	if _, err := w.Write(report.SerializeForPrometheus()); err != nil {
+		return err
+	}
+
+	// execute next formatter if it exists
+	return formatNext(fp, report, w)
+}
+
+// Gets next formatter
+func (fp *FormatPrometheus) Next() OutputFormatter {
+	return fp.next
+}
+
+// Sets next formatter
+func (fp *FormatPrometheus) SetNext(next OutputFormatter) {
+	fp.next = next
+}
@@ -12,7 +12,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -40,27 +42,121 @@ const (
 
 	// Lynis end time
 	KEY_REPORT_DATETIME_END string = `report_datetime_end`
+
+	// Hostname of scanned system
+	KEY_HOSTNAME string = `hostname`
+
+	// Unique ID of scanned system
+	KEY_HOSTID string = `hostid`
+
+	// Lynis hardening index
+	KEY_HARDENING_INDEX string = `hardening_index`
+
+	// Tests executed during scan separated by |
+	KEY_TESTS_EXECUTED string = `tests_executed`
+
+	// Tests skipped during scan separated by |
+	KEY_TESTS_SKIPPED string = `tests_skipped`
+
+	// Amount of tests performed by Lynis
+	KEY_LYNIS_TESTS_DONE string = `lynis_tests_done`
+
+	// Set when the scan finished
+	KEY_FINISH string = `finish`
+
+	// Auditor that performed the scan
+	KEY_AUDITOR string = `auditor`
+
+	// Major version of the report format
+	KEY_REPORT_VERSION_MAJOR string = `report_version_major`
+
+	// Minor version of the report format
+	KEY_REPORT_VERSION_MINOR string = `report_version_minor`
+
+	// Check that has to be performed manually
+	KEY_MANUAL string = `manual[]`
+
+	// Data collected by a test
+	KEY_DETAILS string = `details[]`
+
+	// File that was deleted while it was still in use
+	KEY_DELETED_FILE string = `deleted_file[]`
+
+	// State of USB storage
+	KEY_USB_STORAGE string = `usb_storage`
+
+	// Prefix of keys describing the firewall
+	KEY_FIREWALL_PREFIX string = `firewall_`
+
+	// Prefix of keys set by plugins
+	KEY_PLUGIN_PREFIX string = `plugin_`
+
+	// Set when plugins are enabled
+	KEY_PLUGINS_ENABLED string = `plugins_enabled`
+
+	// Plugins enabled in the first phase of the scan
+	KEY_PLUGIN_ENABLED_PHASE1 string = `plugin_enabled_phase1[]`
+
+	// Plugins enabled in the second phase of the scan
+	KEY_PLUGIN_ENABLED_PHASE2 string = `plugin_enabled_phase2[]`
 )
 
 const (
 	// Minimum compatable version of Lynis
 	VER string = "3.0.7"
+
+	// Format of the time fields after being formatted to ISO8601
+	TIME_FMT string = "2006-01-02T15:04:05-0700"
+
+	// Major version of the report format that can be parsed
+	REPORT_VERSION_MAJOR int = 1
 )
 
 // Report struct that represents a Lynis Report
 type Report struct {
-	LynisVersion  string           `json:"lynisVersion"`
-	DateTimeStart string           `json:"datetime_start"`
-	DateTimeEnd   string           `json:"datetime_end"`
-	Tests         map[string]*Test `json:"tests"`
-	nonline       *regexp.Regexp   // regex used to determine non elements
+	LynisVersion   string                    `json:"lynisVersion"`
+	DateTimeStart  string                    `json:"datetime_start"`
+	DateTimeEnd    string                    `json:"datetime_end"`
+	Hostname       string                    `json:"hostname"`
+	HostID         string                    `json:"hostid"`
+	HardeningIndex int                       `json:"hardening_index"`
+	TestsExecuted  []string                  `json:"tests_executed,omitempty"`
+	TestsSkipped   []string                  `json:"tests_skipped,omitempty"`
+	LynisTestsDone int                       `json:"lynis_tests_done"`
+	Finished       bool                      `json:"finished"`
+	Auditor        string                    `json:"auditor,omitempty"`
+	VersionMajor   int                       `json:"report_version_major"`
+	VersionMinor   int                       `json:"report_version_minor"`
+	Tests          map[string]*Test          `json:"tests"`
+	Manual         []string                  `json:"manual,omitempty"`
+	DeletedFiles   []string                  `json:"deleted_files,omitempty"`
+	USBStorage     string                    `json:"usb_storage,omitempty"`
+	Firewall       map[string][]string       `json:"firewall,omitempty"`
+	PluginsEnabled bool                      `json:"plugins_enabled"`
+	EnabledPlugins []*Plugin                 `json:"enabled_plugins,omitempty"`
+	Plugins        map[string][]string       `json:"plugins,omitempty"`
+	Executions     map[string]*TestExecution `json:"executions,omitempty"`
+	Values         map[string][]string       `json:"-"`
+	nonline        *regexp.Regexp            // regex used to determine non elements
+}
+
+// Serializes the report as JSON along with its risk score
+func (r *Report) MarshalJSON() ([]byte, error) {
+	type report Report // without MarshalJSON method
+	return json.Marshal(&struct {
+		*report
+		RiskScore int `json:"risk_score"`
+	}{(*report)(r), r.RiskScore()})
 }
 
 // Initializes a new report
 func NewReport() *Report {
 	return &Report{
-		Tests:   make(map[string]*Test),
-		nonline: regexp.MustCompile(REPORT_NON_LINE_REG),
+		Tests:    make(map[string]*Test),
+		Values:   make(map[string][]string),
+		Firewall: make(map[string][]string),
+		Plugins:  make(map[string][]string),
+		nonline:  regexp.MustCompile(REPORT_NON_LINE_REG),
 	}
 }
 
@@ -77,6 +173,45 @@ func CreateReport(input io.Reader) (*Report, error) {
 	return report, nil
 }
 
+// Reads report file and creates report from it
+func ReadReportFile(path string) (*Report, error) {
+	input, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	return CreateReport(input)
+}
+
+// Creates report from JSON serialized by FormatJSON
+func LoadReportJSON(input io.Reader) (*Report, error) {
+	report := NewReport()
+	if err := json.NewDecoder(input).Decode(report); err != nil {
+		return nil, err
+	}
+	if report.Values == nil {
+		report.Values = make(map[string][]string)
+	}
+	if report.Firewall == nil {
+		report.Firewall = make(map[string][]string)
+	}
+	if report.Plugins == nil {
+		report.Plugins = make(map[string][]string)
+	}
+
+	// link tests back to report
+	for name, t := range report.Tests {
+		if t == nil {
+			t = NewTest(name, report)
+			report.Tests[name] = t
+		}
+		t.report = report
+		t.Execution = report.Executions[name]
+	}
+	return report, nil
+}
+
 // Checks that the version of Lynis is compatable
 func CheckVersion(ver string) error {
 	// parse version string
@@ -95,7 +230,7 @@ func CheckVersion(ver string) error {
 			return errors.New("Lynis version string contains non number")
 		}
 
-                // panic since internal version string is invalid
+		// panic since internal version string is invalid
 		valid, err := strconv.Atoi(vValid[i])
 		if err != nil {
 			panic(err)
@@ -121,8 +256,8 @@ func (r *Report) ProcessLine(line string) error {
 	// get key and value from line
 	key, value, err := parseKeyValue(line)
 	if err != nil {
-                // ignore line
-                // TODO send warning message
+		// ignore line
+		// TODO send warning message
 		return nil
 	}
 
@@ -133,38 +268,70 @@ func (r *Report) ProcessLine(line string) error {
 	return nil
 }
 
-// Adds key and value to the Report struct, if key is not supported it is
-// ignored
+// Adds key and value to the Report struct using the handler registered for
+// the key, if key is not supported its raw value is stored in Values
 func (r *Report) Add(key, value string) error {
-	var err error
-
-	// process key
-	switch key {
-	case KEY_LYNISVER:
-		// check lynis version
-		err = CheckVersion(value)
-		r.LynisVersion = value
-	case KEY_WARNING:
-		// add value to Warning slice for test
-		_, err = r.parseTestValues(value, AddWarning)
-	case KEY_SUGGESTION:
-		// add value to Suggestion slice for test
-		_, err = r.parseTestValues(value, AddSuggestion)
-	case KEY_REPORT_DATETIME_START:
-		// set date time start
-		r.DateTimeStart, err = FormatTime(value)
-	case KEY_REPORT_DATETIME_END:
-		// set date time end
-		r.DateTimeEnd, err = FormatTime(value)
-	default:
-		return nil
+	if handle := keyHandler(key); handle != nil {
+		return handle(r, key, value)
 	}
-	return err
+
+	// store raw value
+	r.AddValue(key, value)
+	return nil
+}
+
+// Stores the raw value of key in Values, it can be used by handlers of keys
+// to keep the value along with processing it
+func (r *Report) AddValue(key, value string) {
+	addValue(r.Values, key, value)
+}
+
+// Adds value of key to values, array keys ending with [] are stored without
+// the trailing [] and keep every value
+func addValue(values map[string][]string, key, value string) {
+	name := strings.TrimSuffix(key, "[]")
+	if name != key {
+		values[name] = append(values[name], value)
+	} else {
+		values[name] = []string{value}
+	}
+}
+
+// Returns the raw values of a key that is not otherwise supported, keys of
+// arrays are given without the trailing [] eg. running_service. Values of
+// deleted files, USB storage, firewall and plugin keys are also returned, the
+// names of plugins are returned for the keys of enabled plugins
+func (r *Report) Value(key string) []string {
+	key = strings.TrimSuffix(key, "[]")
+	switch {
+	case key+"[]" == KEY_DELETED_FILE:
+		return r.DeletedFiles
+	case key == KEY_USB_STORAGE:
+		if len(r.USBStorage) < 1 {
+			return nil
+		}
+		return []string{r.USBStorage}
+	case key == KEY_PLUGINS_ENABLED:
+		if r.PluginsEnabled {
+			return []string{"1"}
+		}
+		return []string{"0"}
+	case key+"[]" == KEY_PLUGIN_ENABLED_PHASE1:
+		return r.pluginNames(1)
+	case key+"[]" == KEY_PLUGIN_ENABLED_PHASE2:
+		return r.pluginNames(2)
+	case strings.HasPrefix(key, KEY_FIREWALL_PREFIX):
+		return r.Firewall[strings.TrimPrefix(key, KEY_FIREWALL_PREFIX)]
+	case strings.HasPrefix(key, KEY_PLUGIN_PREFIX):
+		return r.Plugins[strings.TrimPrefix(key, KEY_PLUGIN_PREFIX)]
+	}
+	return r.Values[key]
 }
 
 // Serialize Report struct so it is compatable to be ingested by Elasticsearch
-func (r *Report) SerializeForElasticSearch() ([]byte, error) {
-	teesData := make([]byte, 0)
+// and write it to Writer. Each test element is written as a complete line so
+// output is never left with a partial element
+func (r *Report) SerializeForElasticSearch(w io.Writer) error {
 	tees, _ := r.CreateTestElementElastics()
 
 	// Marshal each test specific for Elasticsearch
@@ -172,14 +339,15 @@ func (r *Report) SerializeForElasticSearch() ([]byte, error) {
 
 		data, err := json.Marshal(te)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		teesData = append(teesData, data...)
-		teesData = append(teesData, '\n')
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return err
+		}
 	}
 
-	return teesData, nil
+	return nil
 }
 
 // Creates a slice of TestElementElastic elements
@@ -190,10 +358,61 @@ func (r *Report) CreateTestElementElastics() ([]*TestElementElastic, error) {
 	for _, t := range r.Tests {
 		tees = append(tees, t.CreateTestElementElastics()...)
 	}
+	tees = append(tees, r.createEntryElastics()...)
 
 	return tees, nil
 }
 
+// Returns the amount of warnings and suggestions found in the report
+func (r *Report) Count() (warnings int, suggestions int) {
+	for _, t := range r.Tests {
+		warnings += len(t.Warnings)
+		suggestions += len(t.Suggestions)
+	}
+	return
+}
+
+// Returns the amount of warnings and suggestions found in the report that are
+// not suppressed, these are the totals of every output
+func (r *Report) CountUnsuppressed() (warnings int, suggestions int) {
+	for _, t := range r.Tests {
+		for _, f := range t.Findings() {
+			if f.Element.Suppressed {
+				continue
+			}
+			if f.Type == "warning" {
+				warnings++
+			} else {
+				suggestions++
+			}
+		}
+	}
+	return
+}
+
+// Returns the names of all tests in the report sorted alphabetically
+func (r *Report) TestNames() []string {
+	names := make([]string, 0, len(r.Tests))
+	for name := range r.Tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the duration of the scan, zero if the start or end time is missing
+func (r *Report) Duration() time.Duration {
+	start, err := ParseTime(r.DateTimeStart)
+	if err != nil {
+		return 0
+	}
+	end, err := ParseTime(r.DateTimeEnd)
+	if err != nil {
+		return 0
+	}
+	return end.Sub(start)
+}
+
 // Formats the Lynis time fields to ISO8601
 func FormatTime(timestr string) (string, error) {
 	// get current time
@@ -204,7 +423,12 @@ func FormatTime(timestr string) (string, error) {
 		timestr+now.Format("-0700"))
 
 	// return formatted time
-	return timefmt.Format("2006-01-02T15:04:05-0700"), err
+	return timefmt.Format(TIME_FMT), err
+}
+
+// Parses a time field that was formatted by FormatTime
+func ParseTime(timestr string) (time.Time, error) {
+	return time.Parse(TIME_FMT, timestr)
 }
 
 // Adds test to report by creating a new Test with the name passed to function
@@ -250,9 +474,20 @@ func (r *Report) parseTestValues(value string,
 	return test, nil
 }
 
+// Parses a list of values separated by | from Lynis report
+func parseList(value string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(value, "|") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 // Parses key value pair from Lynis report
 func parseKeyValue(line string) (key string, value string, err error) {
-	keyValues := strings.SplitN(line, "=",2)
+	keyValues := strings.SplitN(line, "=", 2)
 	if len(keyValues) != 2 {
 		return "", "", errors.New("malformed line")
 	}
@@ -0,0 +1,833 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Rule is a named expression a report must satisfy
+type Rule struct {
+	Name    string `json:"name"`
+	Expr    string `json:"expr"`              // expression evaluated on report
+	Message string `json:"message,omitempty"` // reported when rule fails
+
+	expr *Expr // compiled Expr
+}
+
+// Rules is the list of rules read from a rules file
+type Rules struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// RuleResult is the outcome of evaluating a rule on a report
+type RuleResult struct {
+	Name    string `json:"name"`
+	Pass    bool   `json:"pass"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Reads and compiles rules from JSON
+func LoadRules(input io.Reader) (*Rules, error) {
+	var rs Rules
+	decoder := json.NewDecoder(input)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rs); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rs.Rules {
+		if len(rule.Name) < 1 {
+			return nil, errors.New(fmt.Sprintf("rule %d: missing name", i+1))
+		}
+		if err := rule.Compile(); err != nil {
+			return nil, errors.New(fmt.Sprintf("rule %s: %s", rule.Name, err))
+		}
+	}
+	return &rs, nil
+}
+
+// Compiles the expression of the rule
+func (rule *Rule) Compile() error {
+	var err error
+	rule.expr, err = ParseExpr(rule.Expr)
+	return err
+}
+
+// Evaluates the rule on the report
+func (rule *Rule) Evaluate(r *Report) *RuleResult {
+	result := &RuleResult{Name: rule.Name}
+	if rule.expr == nil {
+		if err := rule.Compile(); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	pass, err := rule.expr.Eval(r)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Pass = pass
+	if !pass {
+		result.Message = rule.Message
+		if len(result.Message) < 1 {
+			result.Message = rule.Expr
+		}
+	}
+	return result
+}
+
+// Evaluates every rule on the report
+func (rs *Rules) Evaluate(r *Report) []*RuleResult {
+	results := make([]*RuleResult, 0, len(rs.Rules))
+	for _, rule := range rs.Rules {
+		results = append(results, rule.Evaluate(r))
+	}
+	return results
+}
+
+// Expr is a compiled expression that is evaluated on a report. Expressions
+// support
+//
+//	hardening_index >= 80                       comparisons of numbers and strings
+//	hostname =~ "web*"                          glob patterns
+//	lynis_version ~ "^3\.0"                     regular expressions
+//	"telnet" not in running_service             values of report keys
+//	count(warnings where test =~ "SSH-*") == 0  counting findings
+//	a && b, a || b, !a, (a)                     logic
+//
+// Fields of the report are hardening_index, hostname, hostid, lynis_version,
+// risk_score, auditor, lynis_tests_done, finished, tests_executed,
+// tests_skipped, warnings, suggestions, findings and the raw values of any
+// other report key eg. running_service. Within the where clause of count the fields
+// of a finding are test, type, category, message, details, solution and
+// severity. Counts exclude suppressed findings. A string can be prefixed with
+// a word that describes it, eg. service "telnet" not in running_service
+type Expr struct {
+	source string
+	root   node
+}
+
+// Parses an expression
+func ParseExpr(source string) (*Expr, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, errors.New(fmt.Sprintf("unexpected %s at %d", tok.text,
+			tok.pos))
+	}
+	return &Expr{source, root}, nil
+}
+
+// Evaluates the expression on the report, it must result in true or false
+func (e *Expr) Eval(r *Report) (bool, error) {
+	v, err := e.root.eval(&evalContext{report: r})
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.New(fmt.Sprintf(
+			"expression %s does not result in true or false", e.source))
+	}
+	return b, nil
+}
+
+// Returns the source of the expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Kinds of tokens of an expression
+const (
+	tokEOF int = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+// token is a lexical element of an expression
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// Operators of expressions, longest first so they are matched greedily
+var exprOps = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||",
+	"<", ">", "~", "!", "(", ")"}
+
+// Splits the expression into tokens
+func lex(source string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			// string literal with escapes
+			j := i + 1
+			for ; j < len(source) && source[j] != '"'; j++ {
+				if source[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(source) {
+				return nil, errors.New(fmt.Sprintf(
+					"unterminated string at %d", i))
+			}
+			text, err := strconv.Unquote(source[i : j+1])
+			if err != nil {
+				// keep backslashes of patterns such as "\d"
+				text = source[i+1 : j]
+			}
+			tokens = append(tokens, token{tokString, text, i})
+			i = j + 1
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(source) && (unicode.IsDigit(rune(source[j])) ||
+				source[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, source[i:j], i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(source) && (unicode.IsLetter(rune(source[j])) ||
+				unicode.IsDigit(rune(source[j])) || source[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{tokIdent, source[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range exprOps {
+				if strings.HasPrefix(source[i:], o) {
+					op = o
+					break
+				}
+			}
+			if len(op) < 1 {
+				return nil, errors.New(fmt.Sprintf(
+					"unexpected character %c at %d", c, i))
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "end of expression", len(source)}),
+		nil
+}
+
+// parser builds the syntax tree of an expression from its tokens
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Returns the current token
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// Returns the current token and moves to the next one
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// Returns true and moves to the next token if the current token is an
+// operator or keyword with the text
+func (p *parser) accept(text string) bool {
+	tok := p.peek()
+	if (tok.kind == tokOp || tok.kind == tokIdent) && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// Moves to the next token if the current token is the operator or returns an
+// error
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		tok := p.peek()
+		return errors.New(fmt.Sprintf("expected %s at %d found %s", text,
+			tok.pos, tok.text))
+	}
+	return nil
+}
+
+// or := and { ("||" | "or") and }
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") || p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{"||", left, right}
+	}
+	return left, nil
+}
+
+// and := not { ("&&" | "and") not }
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") || p.accept("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{"&&", left, right}
+	}
+	return left, nil
+}
+
+// not := ("!" | "not") not | comparison
+func (p *parser) parseNot() (node, error) {
+	if p.accept("!") || p.accept("not") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x}, nil
+	}
+	return p.parseComparison()
+}
+
+// comparison := primary [ OP primary | ["not"] "in" primary ]
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.accept("in") {
+		list, err := p.parsePrimary()
+		return &inNode{left, list, false}, err
+	}
+	if p.peek().text == "not" && p.tokens[p.pos+1].text == "in" {
+		p.pos += 2
+		list, err := p.parsePrimary()
+		return &inNode{left, list, true}, err
+	}
+
+	tok := p.peek()
+	if tok.kind != tokOp {
+		return left, nil
+	}
+	switch tok.text {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~", "~":
+		p.next()
+	default:
+		return left, nil
+	}
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	cmp := &compareNode{op: tok.text, left: left, right: right}
+	if tok.text == "~" {
+		// compile regular expression once if it is a literal
+		if lit, ok := right.(*literalNode); ok {
+			pattern, _ := lit.value.(string)
+			if cmp.re, err = regexp.Compile(pattern); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return cmp, nil
+}
+
+// primary := NUMBER | STRING | [IDENT] STRING | "true" | "false" |
+//
+//	"count" "(" IDENT ["where" or] ")" | IDENT | "(" or ")"
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid number %s at %d",
+				tok.text, tok.pos))
+		}
+		return &literalNode{n}, nil
+	case tokString:
+		return &literalNode{tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "count":
+			return p.parseCount()
+		}
+		// word describing the string that follows it
+		if p.peek().kind == tokString {
+			return &literalNode{p.next().text}, nil
+		}
+		return &fieldNode{tok.text}, nil
+	case tokOp:
+		if tok.text == "(" {
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("unexpected %s at %d", tok.text,
+		tok.pos))
+}
+
+// Parses the arguments of count after the count keyword
+func (p *parser) parseCount() (node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	tok := p.next()
+	if tok.kind != tokIdent {
+		return nil, errors.New(fmt.Sprintf("expected field at %d found %s",
+			tok.pos, tok.text))
+	}
+	count := &countNode{source: tok.text}
+	if p.accept("where") {
+		var err error
+		if count.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	return count, p.expect(")")
+}
+
+// evalContext is what fields of an expression are resolved against
+type evalContext struct {
+	report  *Report
+	finding *Finding // finding in where clause of count
+	value   *string  // value in where clause of count of report key
+}
+
+// node of the syntax tree of an expression, values are float64, string,
+// bool or []string
+type node interface {
+	eval(*evalContext) (interface{}, error)
+}
+
+// literalNode is a number, string, true or false
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(c *evalContext) (interface{}, error) {
+	return n.value, nil
+}
+
+// fieldNode is a field of the report or finding
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(c *evalContext) (interface{}, error) {
+	if c.finding != nil {
+		switch n.name {
+		case "test":
+			return c.finding.Test.Name, nil
+		case "type":
+			return c.finding.Type, nil
+		case "category":
+			category := altValue{TestCategory(c.finding.Test.Name)}
+			if len(c.finding.Test.Category) > 0 {
+				category = append(category, c.finding.Test.Category)
+			}
+			return category, nil
+		case "message":
+			return c.finding.Element.Message, nil
+		case "details":
+			return c.finding.Element.Details, nil
+		case "solution":
+			return c.finding.Element.Solution, nil
+		case "severity":
+			return c.finding.Severity(), nil
+		}
+	}
+	if c.value != nil && n.name == "value" {
+		return *c.value, nil
+	}
+
+	r := c.report
+	switch n.name {
+	case "hardening_index":
+		return float64(r.HardeningIndex), nil
+	case "hostname":
+		return r.Hostname, nil
+	case "hostid":
+		return r.HostID, nil
+	case "lynis_version":
+		return r.LynisVersion, nil
+	case "risk_score":
+		return float64(r.RiskScore()), nil
+	case "auditor":
+		return r.Auditor, nil
+	case "lynis_tests_done":
+		return float64(r.LynisTestsDone), nil
+	case "finished":
+		return r.Finished, nil
+	case "tests_executed":
+		return append([]string{}, r.TestsExecuted...), nil
+	case "tests_skipped":
+		return append([]string{}, r.TestsSkipped...), nil
+	case "warnings", "suggestions", "findings":
+		return float64(len(activeFindings(r, n.name))), nil
+	}
+	// raw values of report key, missing keys have no values
+	values := r.Value(n.name)
+	if values == nil {
+		values = []string{}
+	}
+	return values, nil
+}
+
+// Returns the findings of the report that are not suppressed. The source is
+// warnings, suggestions or findings for both
+func activeFindings(r *Report, source string) []*Finding {
+	findings := make([]*Finding, 0)
+	for _, name := range r.TestNames() {
+		for _, f := range r.Tests[name].Findings() {
+			if f.Element.Suppressed ||
+				(source != "findings" && f.Type+"s" != source) {
+				continue
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// countNode counts findings or values of a report key matching a condition
+type countNode struct {
+	source string
+	where  node
+}
+
+func (n *countNode) eval(c *evalContext) (interface{}, error) {
+	count := 0
+	switch n.source {
+	case "warnings", "suggestions", "findings":
+		for _, f := range activeFindings(c.report, n.source) {
+			match, err := n.match(&evalContext{report: c.report, finding: f})
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				count++
+			}
+		}
+	default:
+		for _, v := range c.report.Value(n.source) {
+			v := v
+			match, err := n.match(&evalContext{report: c.report, value: &v})
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				count++
+			}
+		}
+	}
+	return float64(count), nil
+}
+
+// Returns true if there is no condition or the condition is true
+func (n *countNode) match(c *evalContext) (bool, error) {
+	if n.where == nil {
+		return true, nil
+	}
+	v, err := n.where.eval(c)
+	if err != nil {
+		return false, err
+	}
+	return toBool(v)
+}
+
+// notNode negates a condition
+type notNode struct {
+	x node
+}
+
+func (n *notNode) eval(c *evalContext) (interface{}, error) {
+	v, err := n.x.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toBool(v)
+	return !b, err
+}
+
+// logicNode is && or || of two conditions, the right condition is only
+// evaluated if needed
+type logicNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicNode) eval(c *evalContext) (interface{}, error) {
+	v, err := n.left.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	left, err := toBool(v)
+	if err != nil {
+		return nil, err
+	}
+	if (n.op == "&&" && !left) || (n.op == "||" && left) {
+		return left, nil
+	}
+
+	v, err = n.right.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	return toBool(v)
+}
+
+// inNode checks a value is one of the values of a report key
+type inNode struct {
+	needle, list node
+	negate       bool
+}
+
+func (n *inNode) eval(c *evalContext) (interface{}, error) {
+	v, err := n.needle.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	needle, err := toScalar(v)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err = n.list.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := v.([]string)
+	if !ok {
+		s, _ := toScalar(v)
+		list = []string{toString(s)}
+	}
+
+	found := false
+	for _, item := range list {
+		if item == toString(needle) {
+			found = true
+			break
+		}
+	}
+	return found != n.negate, nil
+}
+
+// compareNode compares two values
+type compareNode struct {
+	op          string
+	left, right node
+	re          *regexp.Regexp // compiled pattern of ~
+}
+
+func (n *compareNode) eval(c *evalContext) (interface{}, error) {
+	v, err := n.left.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	left, err := toScalar(v)
+	if err != nil {
+		return nil, err
+	}
+	v, err = n.right.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	right, err := toScalar(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// values with several forms match if any form matches
+	_, lalt := left.(altValue)
+	_, ralt := right.(altValue)
+	if !lalt && !ralt {
+		return n.compare(left, right)
+	}
+	negate := n.op == "!=" || n.op == "!~"
+	positive := &compareNode{op: n.op, re: n.re}
+	switch n.op {
+	case "!=":
+		positive.op = "=="
+	case "!~":
+		positive.op = "=~"
+	}
+	for _, l := range alternatives(left) {
+		for _, r := range alternatives(right) {
+			match, err := positive.compare(l, r)
+			if err != nil {
+				return nil, err
+			}
+			if match == true {
+				return !negate, nil
+			}
+		}
+	}
+	return negate, nil
+}
+
+// Compares the left and right values with the operator of the node
+func (n *compareNode) compare(left, right interface{}) (interface{}, error) {
+	var err error
+	switch n.op {
+	case "=~", "!~":
+		match, err := path.Match(toString(right), toString(left))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid pattern %s",
+				toString(right)))
+		}
+		return match == (n.op == "=~"), nil
+	case "~":
+		re := n.re
+		if re == nil {
+			if re, err = regexp.Compile(toString(right)); err != nil {
+				return nil, err
+			}
+		}
+		return re.MatchString(toString(left)), nil
+	}
+
+	// compare as numbers if both values are numbers
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		switch n.op {
+		case "==":
+			return toString(left) == toString(right), nil
+		case "!=":
+			return toString(left) != toString(right), nil
+		}
+		return nil, errors.New(fmt.Sprintf("cannot compare %s %s %s",
+			toString(left), n.op, toString(right)))
+	}
+
+	switch n.op {
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	}
+	return l >= r, nil
+}
+
+// Value that can be compared as any of its forms, eg. the category of a test
+// as its prefix NETW or its name Networking. The first form is used otherwise
+type altValue []string
+
+// Returns the forms of a value
+func alternatives(v interface{}) []interface{} {
+	alt, ok := v.(altValue)
+	if !ok {
+		return []interface{}{v}
+	}
+	forms := make([]interface{}, 0, len(alt))
+	for _, a := range alt {
+		forms = append(forms, a)
+	}
+	return forms
+}
+
+// Converts a value to a bool
+func toBool(v interface{}) (bool, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.New(fmt.Sprintf("%v is not true or false", v))
+	}
+	return b, nil
+}
+
+// Converts values of a report key to a single value
+func toScalar(v interface{}) (interface{}, error) {
+	list, ok := v.([]string)
+	if !ok {
+		return v, nil
+	}
+	switch len(list) {
+	case 0:
+		return "", nil
+	case 1:
+		return list[0], nil
+	}
+	return nil, errors.New(fmt.Sprintf("%v has more than one value", list))
+}
+
+// Converts a value to a number, returns false if it is not a number
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Converts a value to a string
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case altValue:
+		return s[0]
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
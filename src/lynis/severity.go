@@ -0,0 +1,256 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+)
+
+// Severities of findings from least to most severe
+const (
+	SEVERITY_INFO     string = "info"
+	SEVERITY_LOW      string = "low"
+	SEVERITY_MEDIUM   string = "medium"
+	SEVERITY_HIGH     string = "high"
+	SEVERITY_CRITICAL string = "critical"
+)
+
+// Severities ordered from least to most severe
+var severities = []string{SEVERITY_INFO, SEVERITY_LOW, SEVERITY_MEDIUM,
+	SEVERITY_HIGH, SEVERITY_CRITICAL}
+
+// Severity model that is built into the binary
+//
+//go:embed severity.json
+var severityJSON []byte
+
+// Model used to rate the severity of findings
+var severityModel = mustLoadSeverityModel(severityJSON)
+
+// SeverityModel rates the severity of findings. The severity of a finding is
+// taken from the first of Overrides for its test and type, Categories for the
+// category of its test and type, and Types for its type. Keys of Overrides are
+// test IDs or glob patterns. The risk score of a report is the sum of Scores
+// of the severities of its findings
+type SeverityModel struct {
+	Types      map[string]string            `json:"types"`
+	Categories map[string]map[string]string `json:"categories"`
+	Overrides  map[string]map[string]string `json:"overrides"`
+	Scores     map[string]int               `json:"scores"`
+}
+
+// Returns the rank of a severity, higher is more severe and -1 if the
+// severity is not valid
+func SeverityRank(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns an error if the severity is not valid
+func ValidateSeverity(severity string) error {
+	if SeverityRank(severity) < 0 {
+		return errors.New(fmt.Sprintf(
+			"invalid severity %s, must be one of info, low, medium, high or critical",
+			severity))
+	}
+	return nil
+}
+
+// Reads and validates a severity model from JSON
+func LoadSeverityModel(input io.Reader) (*SeverityModel, error) {
+	m := &SeverityModel{}
+	decoder := json.NewDecoder(input)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(m); err != nil {
+		return nil, err
+	}
+	if m.Types == nil {
+		m.Types = make(map[string]string)
+	}
+	if m.Categories == nil {
+		m.Categories = make(map[string]map[string]string)
+	}
+	if m.Overrides == nil {
+		m.Overrides = make(map[string]map[string]string)
+	}
+	if m.Scores == nil {
+		m.Scores = make(map[string]int)
+	}
+
+	for _, severity := range m.Types {
+		if err := ValidateSeverity(severity); err != nil {
+			return nil, err
+		}
+	}
+	for _, types := range m.Categories {
+		for _, severity := range types {
+			if err := ValidateSeverity(severity); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for test, types := range m.Overrides {
+		if _, err := path.Match(test, ""); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid test pattern %s",
+				test))
+		}
+		for _, severity := range types {
+			if err := ValidateSeverity(severity); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for severity := range m.Scores {
+		if err := ValidateSeverity(severity); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Reads the built in model, panics since it is part of the binary
+func mustLoadSeverityModel(data []byte) *SeverityModel {
+	m, err := LoadSeverityModel(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Returns a copy of the severity model that is built into the binary
+func DefaultSeverityModel() *SeverityModel {
+	return mustLoadSeverityModel(severityJSON)
+}
+
+// Returns the model used to rate the severity of findings
+func GetSeverityModel() *SeverityModel {
+	return severityModel
+}
+
+// Sets the model used to rate the severity of findings
+func SetSeverityModel(m *SeverityModel) {
+	severityModel = m
+}
+
+// Merges the other model into the model, entries in the other model replace
+// the same entries
+func (m *SeverityModel) Merge(other *SeverityModel) {
+	for typ, severity := range other.Types {
+		m.Types[typ] = severity
+	}
+	for category, types := range other.Categories {
+		if m.Categories[category] == nil {
+			m.Categories[category] = make(map[string]string)
+		}
+		for typ, severity := range types {
+			m.Categories[category][typ] = severity
+		}
+	}
+	for test, types := range other.Overrides {
+		if m.Overrides[test] == nil {
+			m.Overrides[test] = make(map[string]string)
+		}
+		for typ, severity := range types {
+			m.Overrides[test][typ] = severity
+		}
+	}
+	for severity, score := range other.Scores {
+		m.Scores[severity] = score
+	}
+}
+
+// Returns the severity of a finding of the type for the test
+func (m *SeverityModel) Severity(test, typ string) string {
+	if severity, ok := m.Overrides[test][typ]; ok {
+		return severity
+	}
+
+	// patterns are matched in order so the result is always the same
+	patterns := make([]string, 0)
+	for pattern, types := range m.Overrides {
+		if _, ok := types[typ]; !ok {
+			continue
+		}
+		if match, _ := path.Match(pattern, test); match {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) > 0 {
+		sort.Strings(patterns)
+		return m.Overrides[patterns[0]][typ]
+	}
+
+	if severity, ok := m.Categories[TestCategory(test)][typ]; ok {
+		return severity
+	}
+	if severity, ok := m.Types[typ]; ok {
+		return severity
+	}
+	return SEVERITY_INFO
+}
+
+// Returns the score of a severity
+func (m *SeverityModel) Score(severity string) int {
+	return m.Scores[severity]
+}
+
+// Returns the severity of the finding
+func (f *Finding) Severity() string {
+	if len(f.Element.Severity) > 0 {
+		return f.Element.Severity
+	}
+	return severityModel.Severity(f.Test.Name, f.Type)
+}
+
+// Returns the risk score of the report which is the sum of the scores of the
+// severities of findings that are not suppressed
+func (r *Report) RiskScore() int {
+	score := 0
+	for _, t := range r.Tests {
+		for _, f := range t.Findings() {
+			if !f.Element.Suppressed {
+				score += severityModel.Score(f.Severity())
+			}
+		}
+	}
+	return score
+}
+
+// Returns the amount of findings that are not suppressed of each severity
+func (r *Report) CountSeverities() map[string]int {
+	counts := make(map[string]int)
+	for _, s := range severities {
+		counts[s] = 0
+	}
+	for _, t := range r.Tests {
+		for _, f := range t.Findings() {
+			if !f.Element.Suppressed {
+				counts[f.Severity()]++
+			}
+		}
+	}
+	return counts
+}
+
+// Creates a Filter that matches findings of the severity or more severe
+func SeverityFilter(severity string) Filter {
+	rank := SeverityRank(severity)
+	return FilterFunc(func(f *Finding) bool {
+		return SeverityRank(f.Severity()) >= rank
+	})
+}
@@ -0,0 +1,90 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Summary is an overview of a report with the amount of findings and details
+// about the scan. Type is always summary so it can be told apart from
+// findings when ingested into Elasticsearch
+type Summary struct {
+	Type           string         `json:"type"`
+	Hostname       string         `json:"hostname"`
+	HostID         string         `json:"hostid"`
+	LynisVersion   string         `json:"lynisVersion"`
+	ReportVersion  string         `json:"report_version"`
+	DateTimeStart  string         `json:"datetime_start"`
+	DateTimeEnd    string         `json:"datetime_end"`
+	Auditor        string         `json:"auditor,omitempty"`
+	Finished       bool           `json:"finished"`
+	HardeningIndex int            `json:"hardening_index"`
+	TestsExecuted  int            `json:"tests_executed"`
+	TestsSkipped   int            `json:"tests_skipped"`
+	LynisTestsDone int            `json:"lynis_tests_done"`
+	Tests          int            `json:"tests"` // tests with findings
+	Warnings       int            `json:"warnings"`
+	Suggestions    int            `json:"suggestions"`
+	Suppressed     int            `json:"suppressed"`
+	Severities     map[string]int `json:"severities"`
+	RiskScore      int            `json:"risk_score"`
+}
+
+// Returns the summary of the report
+func (r *Report) Summary() *Summary {
+	warnings, suggestions := r.CountUnsuppressed()
+	s := &Summary{
+		Type:           "summary",
+		Hostname:       r.Hostname,
+		HostID:         r.HostID,
+		LynisVersion:   r.LynisVersion,
+		DateTimeStart:  r.DateTimeStart,
+		DateTimeEnd:    r.DateTimeEnd,
+		Auditor:        r.Auditor,
+		Finished:       r.Finished,
+		HardeningIndex: r.HardeningIndex,
+		TestsExecuted:  len(r.TestsExecuted),
+		TestsSkipped:   len(r.TestsSkipped),
+		LynisTestsDone: r.LynisTestsDone,
+		Warnings:       warnings,
+		Suggestions:    suggestions,
+		Severities:     r.CountSeverities(),
+		RiskScore:      r.RiskScore(),
+	}
+	if r.VersionMajor > 0 {
+		s.ReportVersion = fmt.Sprintf("%d.%d", r.VersionMajor, r.VersionMinor)
+	}
+	for _, t := range r.Tests {
+		found := false
+		for _, f := range t.Findings() {
+			if f.Element.Suppressed {
+				s.Suppressed++
+			} else {
+				found = true
+			}
+		}
+		// tests only holding details or manual checks are not counted
+		if found {
+			s.Tests++
+		}
+	}
+	return s
+}
+
+// Serializes the summary of the report as a JSON line and writes it to
+// Writer
+func (r *Report) SerializeSummary(w io.Writer) error {
+	data, err := json.Marshal(r.Summary())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
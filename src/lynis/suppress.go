@@ -0,0 +1,179 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"time"
+)
+
+// Format of the expiry date of a suppression
+const (
+	SUPPRESSION_DATE_FMT string = "2006-01-02"
+)
+
+// Suppression accepts the risk of findings so they are not reported
+type Suppression struct {
+	Test          string `json:"test"`              // test ID or glob pattern
+	Message       string `json:"message,omitempty"` // regular expression
+	Host          string `json:"host,omitempty"`    // hostname glob pattern
+	Justification string `json:"justification"`     // reason risk is accepted
+	Owner         string `json:"owner"`             // who accepted the risk
+	Expires       string `json:"expires"`           // last day of suppression
+
+	message *regexp.Regexp // compiled Message
+	expires time.Time      // time suppression is no longer applied
+	used    bool           // suppression matched a finding
+}
+
+// Suppressions is the list of suppressions read from a suppressions file
+type Suppressions struct {
+	Suppressions []*Suppression `json:"suppressions"`
+}
+
+// Reads and validates suppressions from JSON
+func LoadSuppressions(input io.Reader) (*Suppressions, error) {
+	var s Suppressions
+	decoder := json.NewDecoder(input)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&s); err != nil {
+		return nil, errors.New(fmt.Sprintf(
+			"suppressions must be JSON. %s", err))
+	}
+
+	for i, sup := range s.Suppressions {
+		if err := sup.validate(); err != nil {
+			return nil, errors.New(fmt.Sprintf("suppression %d: %s",
+				i+1, err))
+		}
+	}
+	return &s, nil
+}
+
+// Validates the suppression and compiles its patterns
+func (s *Suppression) validate() error {
+	if len(s.Test) < 1 {
+		return errors.New("missing test")
+	}
+	if _, err := path.Match(s.Test, ""); err != nil {
+		return errors.New(fmt.Sprintf("invalid test pattern %s", s.Test))
+	}
+	if _, err := path.Match(s.Host, ""); err != nil {
+		return errors.New(fmt.Sprintf("invalid host pattern %s", s.Host))
+	}
+	if len(s.Justification) < 1 {
+		return errors.New("missing justification")
+	}
+	if len(s.Owner) < 1 {
+		return errors.New("missing owner")
+	}
+
+	expires, err := time.ParseInLocation(SUPPRESSION_DATE_FMT, s.Expires,
+		time.Local)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid expiry date %s", s.Expires))
+	}
+	// suppression applies until the end of the expiry date
+	s.expires = expires.AddDate(0, 0, 1)
+
+	if len(s.Message) > 0 {
+		s.message, err = regexp.Compile(s.Message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns true if the suppression has expired
+func (s *Suppression) Expired(now time.Time) bool {
+	return !now.Before(s.expires)
+}
+
+// Returns true if the finding of the host matches the suppression
+func (s *Suppression) Match(host string, f *Finding) bool {
+	if match, _ := path.Match(s.Test, f.Test.Name); !match {
+		return false
+	}
+	if len(s.Host) > 0 {
+		if match, _ := path.Match(s.Host, host); !match {
+			return false
+		}
+	}
+	if s.message != nil && !s.message.MatchString(f.Element.Message) {
+		return false
+	}
+	return true
+}
+
+// Applies the suppressions to the report. Suppressed findings are removed
+// from the returned report, or left in the report and marked as suppressed
+// if mark is set. Expired suppressions are not applied and are returned as
+// diagnostics along with suppressions that did not match any finding
+func (ss *Suppressions) Apply(r *Report, now time.Time,
+	mark bool) (*Report, []string) {
+
+	diagnostics := make([]string, 0)
+	active := make([]*Suppression, 0, len(ss.Suppressions))
+	for _, s := range ss.Suppressions {
+		s.used = false
+		if s.Expired(now) {
+			diagnostics = append(diagnostics, fmt.Sprintf(
+				"suppression of %s owned by %s expired on %s: %s",
+				s.Test, s.Owner, s.Expires, s.Justification))
+			continue
+		}
+		active = append(active, s)
+	}
+
+	suppressed := FilterFunc(func(f *Finding) bool {
+		for _, s := range active {
+			if s.Match(r.Hostname, f) {
+				s.used = true
+				return true
+			}
+		}
+		return false
+	})
+
+	if mark {
+		for _, t := range r.Tests {
+			for _, w := range t.Warnings {
+				w.Suppressed = suppressed(&Finding{t, "warning", w})
+			}
+			for _, s := range t.Suggestions {
+				s.Suppressed = suppressed(&Finding{t, "suggestion", s})
+			}
+			for _, m := range t.Manual {
+				m.Suppressed = suppressed(&Finding{t, "manual", m})
+			}
+			for _, d := range t.Details {
+				d.Suppressed = suppressed(&Finding{t, "detail",
+					d.TestElement()})
+			}
+		}
+	} else {
+		r = r.Filter(FilterFunc(func(f *Finding) bool {
+			return !suppressed(f)
+		}))
+	}
+
+	for _, s := range active {
+		if !s.used {
+			diagnostics = append(diagnostics, fmt.Sprintf(
+				"suppression of %s owned by %s did not match any finding",
+				s.Test, s.Owner))
+		}
+	}
+
+	return r, diagnostics
+}
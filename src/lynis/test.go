@@ -6,34 +6,73 @@ package lynis
 *   Date: 2022-04-06
  */
 
+import (
+	"strings"
+)
+
 // Test struct that represents a test performed in Lynis scan
 type Test struct {
 	Name        string         `json:"testname"`
+	Category    string         `json:"category,omitempty"`
+	Title       string         `json:"title,omitempty"`
+	Reference   string         `json:"reference,omitempty"`
 	Warnings    []*TestElement `json:"warnings"`
 	Suggestions []*TestElement `json:"suggestions"`
+	Manual      []*TestElement `json:"manual,omitempty"`
+	Details     []*TestDetail  `json:"details,omitempty"`
+	Execution   *TestExecution `json:"-"`
 	report      *Report
 }
 
-// Create new Test object
+// Create new Test object, details of test are added from the catalogue and
+// it is linked to its execution if the log was added to the report
 func NewTest(name string, r *Report) *Test {
+	info := catalogue.Lookup(name)
+	var execution *TestExecution
+	if r != nil {
+		execution = r.Executions[name]
+	}
 	return &Test{
 		Name:        name,
+		Category:    info.Category,
+		Title:       info.Title,
+		Reference:   info.Reference,
 		Warnings:    make([]*TestElement, 0),
 		Suggestions: make([]*TestElement, 0),
+		Execution:   execution,
 		report:      r,
 	}
 }
 
-// Adds TestElement to Warnings map
+// Adds TestElement to Warnings map, its severity is rated if it is not set
 func AddWarning(t *Test, te *TestElement) {
+	if len(te.Severity) < 1 {
+		te.Severity = severityModel.Severity(t.Name, "warning")
+	}
 	t.Warnings = append(t.Warnings, te)
 }
 
-// Adds TestElement to Suggestions map
+// Adds TestElement to Suggestions map, its severity is rated if it is not
+// set
 func AddSuggestion(t *Test, te *TestElement) {
+	if len(te.Severity) < 1 {
+		te.Severity = severityModel.Severity(t.Name, "suggestion")
+	}
 	t.Suggestions = append(t.Suggestions, te)
 }
 
+// Returns all warnings and suggestions of the test as findings
+func (t *Test) Findings() []*Finding {
+	findings := make([]*Finding, 0, len(t.Warnings)+len(t.Suggestions))
+	for _, w := range t.Warnings {
+		findings = append(findings, &Finding{t, "warning", w})
+	}
+	for _, s := range t.Suggestions {
+		findings = append(findings, &Finding{t, "suggestion", s})
+	}
+	return findings
+}
+
 // Creates TestElementElastic elements from test and returns them as a slice
 func (t *Test) CreateTestElementElastics() []*TestElementElastic {
         // Create slice to fit all Warnings and suggestions
@@ -55,6 +94,24 @@ func (t *Test) CreateTestElementElastics() []*TestElementElastic {
 			t.report, t, s)
 	}
 
+        // add manual checks and details
+	for _, m := range t.Manual {
+		tee, _ := CreateTestElementElastic("manual", t.report, t, m)
+		tees = append(tees, tee)
+	}
+	for _, d := range t.Details {
+		tee, _ := CreateTestElementElastic("detail", t.report, t,
+			d.TestElement())
+		tees = append(tees, tee)
+	}
+
         // return slice
 	return tees
 }
+
+// Returns the category of a test which is the prefix of the test name before
+// the '-' character, eg. NETW for NETW-3200
+func TestCategory(name string) string {
+	category, _, _ := strings.Cut(name, "-")
+	return category
+}
@@ -15,9 +15,11 @@ import (
 
 // TestElement stores details about test details found in a Lynis report
 type TestElement struct {
-	Message  string `json:"message"`
-	Details  string `json:"details"`
-	Solution string `json:"solution"`
+	Message    string `json:"message"`
+	Details    string `json:"details"`
+	Solution   string `json:"solution"`
+	Suppressed bool   `json:"suppressed,omitempty"`
+	Severity   string `json:"severity,omitempty"`
 }
 
 // Crates new TestElement from the string slice. Expected that first element
@@ -37,21 +39,33 @@ func NewTestElement(values []string) (*TestElement, error) {
 			errors.New("element does not have correct amount of fields")
 	}
 
-	return &TestElement{values[0], values[1], values[2]}, nil
+	return &TestElement{
+		Message:  values[0],
+		Details:  values[1],
+		Solution: values[2],
+	}, nil
 }
 
 // TestElementElastic stores details about a test performed in Lynis report
 // stores extra data about the lynis report so that it can be ingested into
 // Elasticsearch.
 type TestElementElastic struct {
-	Name          string `json:"name"`
-	Type          string `json:"type"`
-	LynisVersion  string `json:"lynisVersion"`
-	DateTimeStart string `json:"datetime_start"`
-	DateTimeEnd   string `json:"datetime_end"`
-	Message       string `json:"message"`
-	Details       string `json:"details"`
-	Solution      string `json:"solution"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Category      string   `json:"category,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	Reference     string   `json:"reference,omitempty"`
+	LynisVersion  string   `json:"lynisVersion"`
+	DateTimeStart string   `json:"datetime_start"`
+	DateTimeEnd   string   `json:"datetime_end"`
+	Message       string   `json:"message"`
+	Details       string   `json:"details"`
+	Solution      string   `json:"solution"`
+	Severity      string   `json:"severity"`
+	Suppressed    bool     `json:"suppressed,omitempty"`
+	Controls      []string `json:"controls,omitempty"`
+	Status        string   `json:"status,omitempty"`
+	Duration      *float64 `json:"duration_seconds,omitempty"`
 }
 
 // CreateTestElementElastic creates a TestElementElastic which is a flattened
@@ -59,14 +73,26 @@ type TestElementElastic struct {
 func CreateTestElementElastic(typ string,
 	r *Report, t *Test, te *TestElement) (*TestElementElastic, error) {
 
-	return &TestElementElastic{
+	tee := &TestElementElastic{
 		Name:          t.Name,
 		Type:          typ,
+		Category:      t.Category,
+		Title:         t.Title,
+		Reference:     t.Reference,
 		LynisVersion:  r.LynisVersion,
 		DateTimeStart: r.DateTimeStart,
 		DateTimeEnd:   r.DateTimeEnd,
 		Message:       te.Message,
 		Details:       te.Details,
 		Solution:      te.Solution,
-	}, nil
+		Severity:      (&Finding{t, typ, te}).Severity(),
+		Suppressed:    te.Suppressed,
+		Controls:      complianceMapping.Controls(t.Name),
+	}
+	if t.Execution != nil {
+		tee.Status = t.Execution.Status
+		duration := t.Execution.Duration
+		tee.Duration = &duration
+	}
+	return tee, nil
 }
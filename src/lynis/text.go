@@ -0,0 +1,118 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Serializes the report into a human readable summary and writes it to
+// Writer
+func (r *Report) SerializeForText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	warnings, suggestions := r.CountUnsuppressed()
+
+	// summary of the scan
+	fmt.Fprintf(buf, "Lynis %s report", r.LynisVersion)
+	if len(r.Hostname) > 0 {
+		fmt.Fprintf(buf, " for %s", r.Hostname)
+	}
+	buf.WriteRune('\n')
+	if len(r.DateTimeEnd) > 0 {
+		fmt.Fprintf(buf, "  Scan finished:   %s (took %s)\n",
+			r.DateTimeEnd, r.Duration())
+	}
+	fmt.Fprintf(buf, "  Hardening index: %d\n", r.HardeningIndex)
+	if len(r.TestsExecuted) > 0 {
+		fmt.Fprintf(buf, "  Tests executed:  %d (%d skipped)\n",
+			len(r.TestsExecuted), len(r.TestsSkipped))
+	}
+	fmt.Fprintf(buf, "  Warnings:        %d\n", warnings)
+	fmt.Fprintf(buf, "  Suggestions:     %d\n", suggestions)
+	fmt.Fprintf(buf, "  Risk score:      %d\n", r.RiskScore())
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	// sort tests so output is always in the same order
+	names := r.TestNames()
+
+	// list findings of each type
+	for _, section := range []struct {
+		heading  string
+		typ      string
+		count    int
+		elements func(*Test) []*TestElement
+	}{
+		{"Warnings", "warning", warnings,
+			func(t *Test) []*TestElement { return t.Warnings }},
+		{"Suggestions", "suggestion", suggestions,
+			func(t *Test) []*TestElement { return t.Suggestions }},
+	} {
+		if section.count == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "\n%s:\n", section.heading); err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			for _, te := range section.elements(r.Tests[name]) {
+				buf.Reset()
+				fmt.Fprintf(buf, "  [%s] (%s) %s", name,
+					(&Finding{r.Tests[name], section.typ, te}).Severity(),
+					te.Message)
+				if te.Suppressed {
+					buf.WriteString(" (suppressed)")
+				}
+				buf.WriteRune('\n')
+				if len(te.Details) > 0 && te.Details != "-" {
+					fmt.Fprintf(buf, "      Details:  %s\n", te.Details)
+				}
+				if len(te.Solution) > 0 && te.Solution != "-" {
+					fmt.Fprintf(buf, "      Solution: %s\n", te.Solution)
+				}
+				if _, err := w.Write(buf.Bytes()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// OutputFormatter that will format report as a human readable summary
+type FormatText struct {
+	next OutputFormatter
+}
+
+// Serializes Report into a human readable summary and writes it to Writer
+func (ft *FormatText) Format(report *Report, w io.Writer) error {
+	// add space between data if data has already been written
+	if err := separate(w); err != nil {
+		return err
+	}
+	if err := report.SerializeForText(w); err != nil {
+		return err
+	}
+
+	// execute next formatter if it exists
+	return formatNext(ft, report, w)
+}
+
+// Gets next formatter
+func (ft *FormatText) Next() OutputFormatter {
+	return ft.next
+}
+
+// Sets next formatter
+func (ft *FormatText) SetNext(next OutputFormatter) {
+	ft.next = next
+}
@@ -0,0 +1,204 @@
+package lynis
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Trend is the analysis of a series of reports of a host
+type Trend struct {
+	Points []*TrendPoint `json:"points"`
+	Tests  []*TestTrend  `json:"tests"`
+}
+
+// TrendPoint summarizes a single report in the series
+type TrendPoint struct {
+	Time           time.Time `json:"time"`
+	Warnings       int       `json:"warnings"`
+	Suggestions    int       `json:"suggestions"`
+	HardeningIndex int       `json:"hardening_index"`
+}
+
+// TestTrend is how findings of a test changed over the series. A test is
+// remediated when it has findings in one report and none in the next, the
+// time to remediate is from the first report of the run of reports it had
+// findings in. Tests that have findings again after being remediated are
+// flapping
+type TestTrend struct {
+	Name                string  `json:"name"`
+	Reports             int     `json:"reports"`
+	Appearances         int     `json:"appearances"`
+	Remediations        int     `json:"remediations"`
+	MeanTimeToRemediate float64 `json:"mean_time_to_remediate_seconds"`
+	Flapping            bool    `json:"flapping"`
+	Current             bool    `json:"current"`
+}
+
+// Analyzes a series of reports of a host. Reports are sorted by the time of
+// their scan and reports without a scan time are skipped, returns an error if
+// the reports are of more than one host
+func Analyze(reports []*Report) (*Trend, error) {
+	type scan struct {
+		time   time.Time
+		report *Report
+	}
+	scans := make([]scan, 0, len(reports))
+	for _, r := range reports {
+		if host := HistoryHost(r); host != HistoryHost(reports[0]) {
+			return nil, errors.New(fmt.Sprintf(
+				"reports are of more than one host, %s and %s",
+				HistoryHost(reports[0]), host))
+		}
+		if t, err := ScanTime(r); err == nil {
+			scans = append(scans, scan{t, r})
+		}
+	}
+	sort.SliceStable(scans, func(i, j int) bool {
+		return scans[i].time.Before(scans[j].time)
+	})
+
+	trend := &Trend{
+		Points: make([]*TrendPoint, 0, len(scans)),
+		Tests:  make([]*TestTrend, 0),
+	}
+	tests := make(map[string]*TestTrend)
+	since := make(map[string]time.Time) // start of current run of findings
+	remediated := make(map[string]time.Duration)
+
+	for i, s := range scans {
+		warnings, suggestions := s.report.CountUnsuppressed()
+		trend.Points = append(trend.Points, &TrendPoint{
+			Time:           s.time,
+			Warnings:       warnings,
+			Suggestions:    suggestions,
+			HardeningIndex: s.report.HardeningIndex,
+		})
+
+		// tests with findings in this report
+		for name, t := range s.report.Tests {
+			if len(t.Warnings)+len(t.Suggestions) < 1 {
+				continue
+			}
+			tt, ok := tests[name]
+			if !ok {
+				tt = &TestTrend{Name: name}
+				tests[name] = tt
+				trend.Tests = append(trend.Tests, tt)
+			}
+			tt.Reports++
+			if _, ok := since[name]; !ok {
+				since[name] = s.time
+				tt.Appearances++
+				if tt.Remediations > 0 {
+					tt.Flapping = true
+				}
+			}
+			tt.Current = i == len(scans)-1
+		}
+
+		// tests without findings in this report have been remediated
+		for name, start := range since {
+			if t, ok := s.report.Tests[name]; ok &&
+				len(t.Warnings)+len(t.Suggestions) > 0 {
+				continue
+			}
+			tests[name].Remediations++
+			remediated[name] += s.time.Sub(start)
+			delete(since, name)
+		}
+	}
+
+	for _, tt := range trend.Tests {
+		if tt.Remediations > 0 {
+			tt.MeanTimeToRemediate = (remediated[tt.Name] /
+				time.Duration(tt.Remediations)).Seconds()
+		}
+	}
+	sort.Slice(trend.Tests, func(i, j int) bool {
+		return trend.Tests[i].Name < trend.Tests[j].Name
+	})
+
+	return trend, nil
+}
+
+// Serializes the trend as JSON and writes it to Writer
+func (tr *Trend) SerializeForJSON(w io.Writer) error {
+	data, err := json.Marshal(tr)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Serializes the points of the trend, or the tests if tests is set, as CSV
+// and writes it to Writer
+func (tr *Trend) SerializeForCSV(w io.Writer, tests bool) error {
+	cw := csv.NewWriter(w)
+	if tests {
+		cw.Write([]string{"test", "reports", "appearances", "remediations",
+			"mean_time_to_remediate_seconds", "flapping", "current"})
+		for _, tt := range tr.Tests {
+			cw.Write([]string{
+				tt.Name,
+				strconv.Itoa(tt.Reports),
+				strconv.Itoa(tt.Appearances),
+				strconv.Itoa(tt.Remediations),
+				strconv.FormatFloat(tt.MeanTimeToRemediate, 'f', 0, 64),
+				strconv.FormatBool(tt.Flapping),
+				strconv.FormatBool(tt.Current),
+			})
+		}
+	} else {
+		cw.Write([]string{"time", "warnings", "suggestions",
+			"hardening_index"})
+		for _, p := range tr.Points {
+			cw.Write([]string{
+				p.Time.Format(time.RFC3339),
+				strconv.Itoa(p.Warnings),
+				strconv.Itoa(p.Suggestions),
+				strconv.Itoa(p.HardeningIndex),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Serializes the trend as text tables and writes it to Writer
+func (tr *Trend) SerializeForText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "TIME\tWARNINGS\tSUGGESTIONS\tHARDENING INDEX")
+	for _, p := range tr.Points {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", p.Time.Format(time.RFC3339),
+			p.Warnings, p.Suggestions, p.HardeningIndex)
+	}
+
+	fmt.Fprintln(tw, "")
+	fmt.Fprintln(tw, "TEST\tREPORTS\tREMEDIATIONS\tMEAN TIME TO REMEDIATE\tFLAPPING\tCURRENT")
+	for _, tt := range tr.Tests {
+		mttr := "-"
+		if tt.Remediations > 0 {
+			mttr = (time.Duration(tt.MeanTimeToRemediate) * time.Second).
+				String()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%t\t%t\n", tt.Name, tt.Reports,
+			tt.Remediations, mttr, tt.Flapping, tt.Current)
+	}
+
+	return tw.Flush()
+}
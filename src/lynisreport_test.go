@@ -1,9 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	flag "github.com/spf13/pflag"
+	"io"
 	"lynisreport/lynis"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -213,3 +225,1740 @@ func TestReportParseInvalidAmountTestFields(t *testing.T) {
 }
 
 
+
+// test pushing report to Loki using a local HTTP server in place of Loki
+func TestReportPushLoki(t *testing.T) {
+	var body []byte
+	var tenant string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != lynis.LOKI_PUSH_PATH {
+				t.Errorf("push sent to %s wanted %s",
+					r.URL.Path, lynis.LOKI_PUSH_PATH)
+			}
+			tenant = r.Header.Get("X-Scope-OrgID")
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	defer server.Close()
+
+	data := &bytes.Buffer{}
+	_, err := lynis.Process(strings.NewReader(testParse1), data,
+		&lynis.FormatLoki{})
+	if err != nil {
+		t.Fatalf("error formatting report: %s", err)
+	}
+
+	client := &lynis.LokiClient{URL: server.URL, Tenant: "lynis"}
+	if err := client.Push(data.Bytes()); err != nil {
+		t.Fatalf("error pushing report: %s", err)
+	}
+	if tenant != "lynis" {
+		t.Errorf("pushed with tenant %s wanted %s", tenant, "lynis")
+	}
+
+	var push lynis.LokiPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		t.Fatalf("error parsing push body: %s", err)
+	}
+
+	// NETW warnings and suggestions are pushed to separate streams
+	if len(push.Streams) != 2 {
+		t.Errorf("pushed %d streams wanted %d", len(push.Streams), 2)
+	}
+	lines := 0
+	for _, s := range push.Streams {
+		if s.Stream["category"] != "NETW" {
+			t.Errorf("stream category %s wanted %s",
+				s.Stream["category"], "NETW")
+		}
+		lines += len(s.Values)
+	}
+	if lines != 9 {
+		t.Errorf("pushed %d lines wanted %d", lines, 9)
+	}
+
+	// errors from Loki are returned
+	client.URL = server.URL + "/missing"
+	server.Config.Handler = http.NotFoundHandler()
+	if err := client.Push(data.Bytes()); err == nil {
+		t.Errorf("expected error pushing to invalid server")
+	}
+}
+
+// test formatting report as Prometheus metrics
+func TestReportFormatPrometheus(t *testing.T) {
+	data := &bytes.Buffer{}
+	_, err := lynis.Process(
+		strings.NewReader(testParse1+"hardening_index=64\n"), data,
+		&lynis.FormatPrometheus{})
+	if err != nil {
+		t.Fatalf("error formatting report: %s", err)
+	}
+
+	metrics := data.String()
+	for _, want := range []string{
+		"lynis_warnings_total 4\n",
+		"lynis_suggestions_total 5\n",
+		"lynis_hardening_index 64\n",
+		`lynis_finding{test_id="NETW-2709",type="warning"} 1` + "\n",
+		`lynis_finding{test_id="NETW-2709",type="suggestion"} 1` + "\n",
+		`lynis_version_info{version="3.0.7"} 1` + "\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+
+	// write metrics atomically and check file is replaced
+	path := filepath.Join(t.TempDir(), "lynis.prom")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := lynis.WriteFileAtomic(path, data.Bytes(), 0644); err != nil {
+		t.Fatalf("error writing metrics: %s", err)
+	}
+	written, _ := os.ReadFile(path)
+	if string(written) != metrics {
+		t.Errorf("metrics file was not replaced")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("found %d files in metrics directory wanted %d",
+			len(entries), 1)
+	}
+}
+
+// test exporter serves metrics and reads report again when it changes
+func TestExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lynis-report.dat")
+	if err := os.WriteFile(path, []byte(testParse1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(lynis.NewExporter(path))
+	defer server.Close()
+
+	scrape := func() string {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("error scraping exporter: %s", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	metrics := scrape()
+	for _, want := range []string{
+		`lynis_report_up{report="` + path + `"} 1`,
+		`lynis_report_parse_errors_total{report="` + path + `"} 0`,
+		`lynis_warnings_total{report="` + path + `"} 4`,
+		"# EOF\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+
+	// invalid report is counted as a parse error
+	if err := os.WriteFile(path, []byte(testParse2), 0644); err != nil {
+		t.Fatal(err)
+	}
+	metrics = scrape()
+	for _, want := range []string{
+		`lynis_report_up{report="` + path + `"} 0`,
+		`lynis_report_parse_errors_total{report="` + path + `"} 1`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
+
+// test formatting report as InfluxDB line protocol
+func TestReportFormatInflux(t *testing.T) {
+	data := &bytes.Buffer{}
+	report, err := lynis.Process(strings.NewReader(testParse1+
+		"report_datetime_end=2022-04-05 13:40:19\nhostname=web 1\n"),
+		data, &lynis.FormatInflux{})
+	if err != nil {
+		t.Fatalf("error formatting report: %s", err)
+	}
+
+	end, _ := lynis.ParseTime(report.DateTimeEnd)
+	ts := strconv.FormatInt(end.UnixNano(), 10)
+
+	lines := strings.Split(strings.TrimSpace(data.String()), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("formatted %d lines wanted %d", len(lines), 10)
+	}
+	want := `lynis_summary,host=web\ 1,lynis_version=3.0.7 warnings=4i,suggestions=5i,hardening_index=0i,duration=240,risk_score=17i ` + ts
+	if lines[0] != want {
+		t.Errorf("formatted summary %s wanted %s", lines[0], want)
+	}
+	want = `lynis_finding,host=web\ 1,test_id=NETW-2706,type=warning,severity=medium message="Couldn't find 2 responsive nameservers",details="-",solution="-",suppressed=false ` + ts
+	if lines[1] != want {
+		t.Errorf("formatted finding %s wanted %s", lines[1], want)
+	}
+}
+
+// test formatting report as OCSF Compliance Findings
+func TestReportFormatOCSF(t *testing.T) {
+	data := &bytes.Buffer{}
+	_, err := lynis.Process(strings.NewReader(testParse1+
+		"hostname=web1\nsuggestion[]=SSH-7408|Consider hardening SSH configuration|MaxAuthTries (6 --> 3)|-|\n"),
+		data, &lynis.FormatOCSF{})
+	if err != nil {
+		t.Fatalf("error formatting report: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(data.String()), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("formatted %d findings wanted %d", len(lines), 10)
+	}
+
+	found := false
+	for _, line := range lines {
+		var finding lynis.OCSFComplianceFinding
+		if err := json.Unmarshal([]byte(line), &finding); err != nil {
+			t.Fatalf("error parsing finding: %s", err)
+		}
+		if finding.ClassUID != lynis.OCSF_CLASS_COMPLIANCE {
+			t.Errorf("finding class %d wanted %d", finding.ClassUID,
+				lynis.OCSF_CLASS_COMPLIANCE)
+		}
+		if finding.Device.Hostname != "web1" {
+			t.Errorf("finding hostname %s wanted %s",
+				finding.Device.Hostname, "web1")
+		}
+		if finding.FindingInfo.UID == "SSH-7408" {
+			found = true
+			if finding.SeverityID != lynis.OCSF_SEVERITY_MEDIUM {
+				t.Errorf("finding severity %d wanted %d",
+					finding.SeverityID, lynis.OCSF_SEVERITY_MEDIUM)
+			}
+			if finding.FindingInfo.Desc != "MaxAuthTries (6 --> 3)" {
+				t.Errorf("finding desc %s", finding.FindingInfo.Desc)
+			}
+			if finding.Remediation != nil {
+				t.Errorf("finding has remediation for empty solution")
+			}
+		}
+	}
+	if !found {
+		t.Errorf("finding SSH-7408 missing")
+	}
+}
+
+// test formatter chain writes output in the order of the chain
+func TestFormatterChain(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1 +
+		"report_datetime_end=2022-04-05 13:40:19\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	formatter := &lynis.FormatTimestamp{}
+	lynis.SetNext(formatter, &lynis.FormatJSON{})
+	lynis.SetNext(formatter, &lynis.FormatNewLine{})
+
+	data := &bytes.Buffer{}
+	if err := lynis.Write(report, data, formatter); err != nil {
+		t.Fatalf("error writing report: %s", err)
+	}
+
+	want := report.DateTimeEnd + " {"
+	if !strings.HasPrefix(data.String(), want) {
+		t.Errorf("output does not start with %q", want)
+	}
+	if !strings.HasSuffix(data.String(), "}\n") {
+		t.Errorf("output does not end with new line")
+	}
+}
+
+// test pending file only writes output to destination when committed
+func TestPendingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lynis.log")
+	if err := os.WriteFile(path, []byte("first\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	// aborted output is never written
+	pf, err := lynis.CreatePendingFile(path, true, 0640)
+	if err != nil {
+		t.Fatalf("error creating pending file: %s", err)
+	}
+	pf.Write([]byte("partial"))
+	pf.Abort()
+
+	// committed output is appended
+	pf, err = lynis.CreatePendingFile(path, true, 0640)
+	if err != nil {
+		t.Fatalf("error creating pending file: %s", err)
+	}
+	pf.Write([]byte("second\n"))
+	if err := pf.Commit(); err != nil {
+		t.Fatalf("error commiting pending file: %s", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "first\nsecond\n" {
+		t.Errorf("log file contains %q wanted %q", data, "first\nsecond\n")
+	}
+}
+
+// OutputFormatter that always fails
+type failingFormatter struct {
+	next lynis.OutputFormatter
+}
+
+// Returns an error without writting output
+func (ff *failingFormatter) Format(*lynis.Report, io.Writer) error {
+	return errors.New("formatting failed")
+}
+
+// Returns the next formatter
+func (ff *failingFormatter) Next() lynis.OutputFormatter {
+	return ff.next
+}
+
+// Sets the next formatter
+func (ff *failingFormatter) SetNext(next lynis.OutputFormatter) {
+	ff.next = next
+}
+
+// test writing report to multiple sinks where one sink fails
+func TestSinks(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	if _, err := parseSink("csv=/tmp/lynis.csv"); err == nil {
+		t.Errorf("expected error parsing sink with unknown format")
+	}
+	if _, err := parseSink("json,upper"); err == nil {
+		t.Errorf("expected error parsing sink with unknown modifier")
+	}
+	if _, err := parseSink("loki"); err == nil {
+		t.Errorf("expected error parsing loki sink without URL")
+	}
+
+	dir := t.TempDir()
+	elastic := filepath.Join(dir, "elastic.log")
+	prom := filepath.Join(dir, "lynis.prom")
+	sinks := make([]*Sink, 0)
+	for _, spec := range []string{
+		"elastic=" + elastic,
+		"json,newline=" + filepath.Join(dir, "missing", "lynis.log"),
+		"prometheus=" + prom,
+	} {
+		sink, err := parseSink(spec)
+		if err != nil {
+			t.Fatalf("error parsing sink %s: %s", spec, err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if code := writeSinks(report, sinks); code != ERR_LOGFILE {
+		t.Errorf("writing sinks returned %d wanted %d", code, ERR_LOGFILE)
+	}
+
+	// sinks after failed sink are still written
+	data, _ := os.ReadFile(elastic)
+	if lines := strings.Count(string(data), "\n"); lines != 10 {
+		t.Errorf("elastic sink has %d lines wanted %d", lines, 10)
+	}
+	data, _ = os.ReadFile(prom)
+	if !strings.Contains(string(data), "lynis_warnings_total 4\n") {
+		t.Errorf("prometheus sink missing warnings metric")
+	}
+
+	// standard output is not written to when formatting fails
+	sink, err := parseSink("json,newline")
+	if err != nil {
+		t.Fatalf("error parsing sink: %s", err)
+	}
+	lynis.SetNext(sink.Formatter, &failingFormatter{})
+	stdout := &bytes.Buffer{}
+	if err := sink.Write(report, stdout); err == nil || stdout.Len() > 0 {
+		t.Errorf("expected no output on error got %q. %v", stdout, err)
+	}
+
+	// Loki is pushed to along with the output format
+	defer func() { fmtElasticOpt, lokiOpt = false, "" }()
+	lokiOpt = "http://localhost:3100"
+	if specs := legacySinks(); len(specs) != 1 ||
+		specs[0] != "loki=http://localhost:3100" {
+		t.Errorf("unexpected sinks %v", specs)
+	}
+	fmtElasticOpt = true
+	if specs := legacySinks(); len(specs) != 2 || specs[0] != "elastic" ||
+		specs[1] != "loki=http://localhost:3100" {
+		t.Errorf("unexpected sinks %v", specs)
+	}
+}
+
+// test filtering findings of report
+func TestReportFilter(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	parse := func(exprs ...string) []lynis.Filter {
+		filters := make([]lynis.Filter, 0)
+		for _, expr := range exprs {
+			f, err := lynis.ParseFilter(expr)
+			if err != nil {
+				t.Fatalf("error parsing filter %s: %s", expr, err)
+			}
+			filters = append(filters, f)
+		}
+		return filters
+	}
+
+	for _, expr := range []string{"severity=severe", "type", "test=[", "message~("} {
+		if _, err := lynis.ParseFilter(expr); err == nil {
+			t.Errorf("expected error parsing filter %s", expr)
+		}
+	}
+
+	for _, c := range []struct {
+		include  []string
+		exclude  []string
+		tests    int
+		elements int
+	}{
+		{[]string{"type=warning"}, nil, 4, 4},
+		{[]string{"test=NETW-32*"}, nil, 4, 4},
+		{[]string{"category=netw"}, []string{"message~dccp"}, 4, 5},
+		{nil, []string{"type=suggestion", "test=NETW-2706"}, 3, 3},
+		{[]string{"details~^-$"}, nil, 8, 9},
+	} {
+		filtered := report.Filter(lynis.IncludeExclude(parse(c.include...),
+			parse(c.exclude...)))
+		tees, _ := filtered.CreateTestElementElastics()
+		if len(filtered.Tests) != c.tests || len(tees) != c.elements {
+			t.Errorf("include %v exclude %v kept %d tests %d elements wanted %d %d",
+				c.include, c.exclude, len(filtered.Tests), len(tees),
+				c.tests, c.elements)
+		}
+	}
+
+	// filter in formatter chain
+	formatter := &lynis.FormatFilter{Filter: lynis.TypeFilter("warning")}
+	formatter.SetNext(&lynis.FormatElasticJSON{})
+	data := &bytes.Buffer{}
+	if err := lynis.Write(report, data, formatter); err != nil {
+		t.Fatalf("error writing report: %s", err)
+	}
+	if lines := strings.Count(data.String(), "\n"); lines != 5 {
+		t.Errorf("wrote %d lines wanted %d", lines, 5)
+	}
+	if len(report.Tests) != 8 {
+		t.Errorf("filter modified original report")
+	}
+}
+
+const (
+	testSuppressions string = `{"suppressions": [
+	{"test": "NETW-27*", "message": "nameservers", "host": "web*",
+	 "justification": "Resolvers are not reachable from the DMZ",
+	 "owner": "ops", "expires": "2030-01-31"},
+	{"test": "NETW-3200", "justification": "dccp is needed",
+	 "owner": "dev", "expires": "2022-01-31"},
+	{"test": "SSH-7408", "justification": "Hardened by config management",
+	 "owner": "ops", "expires": "2030-01-31"}
+]}`
+)
+
+// test suppressing findings of report
+func TestReportSuppress(t *testing.T) {
+	suppressions, err := lynis.LoadSuppressions(
+		strings.NewReader(testSuppressions))
+	if err != nil {
+		t.Fatalf("error loading suppressions: %s", err)
+	}
+
+	// suppressions require justification, owner and expiry date
+	for _, invalid := range []string{
+		`{"suppressions": [{"test": "NETW-3200", "owner": "ops", "expires": "2030-01-31"}]}`,
+		`{"suppressions": [{"test": "NETW-3200", "justification": "x", "expires": "2030-01-31"}]}`,
+		`{"suppressions": [{"test": "NETW-3200", "justification": "x", "owner": "ops", "expires": "soon"}]}`,
+	} {
+		if _, err := lynis.LoadSuppressions(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected error loading suppressions %s", invalid)
+		}
+	}
+
+	// suppressions must be JSON
+	_, err = lynis.LoadSuppressions(strings.NewReader(
+		"suppressions:\n  - test: NETW-3200\n"))
+	if err == nil || !strings.Contains(err.Error(), "must be JSON") {
+		t.Errorf("expected error loading YAML suppressions. %v", err)
+	}
+
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	report, err := lynis.CreateReport(strings.NewReader(testParse1 +
+		"hostname=web1\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	// suppressed findings are removed, expired suppression is not applied
+	suppressed, diagnostics := suppressions.Apply(report, now, false)
+	tees, _ := suppressed.CreateTestElementElastics()
+	if len(tees) != 4 {
+		t.Errorf("kept %d elements wanted %d", len(tees), 4)
+	}
+	if len(diagnostics) != 2 ||
+		!strings.Contains(diagnostics[0], "expired on 2022-01-31") ||
+		!strings.Contains(diagnostics[1], "SSH-7408") {
+		t.Errorf("unexpected diagnostics %v", diagnostics)
+	}
+
+	// suppressed findings are marked
+	marked, _ := suppressions.Apply(report, now, true)
+	tees, _ = marked.CreateTestElementElastics()
+	count := 0
+	for _, tee := range tees {
+		if tee.Suppressed {
+			count++
+		}
+	}
+	if len(tees) != 9 || count != 5 {
+		t.Errorf("marked %d of %d elements wanted %d of %d",
+			count, len(tees), 5, 9)
+	}
+
+	// marked findings are only counted as suppressed in metrics
+	metrics := string(marked.SerializeForPrometheus())
+	for _, want := range []string{
+		"lynis_warnings_total 0\n",
+		"lynis_suggestions_total 4\n",
+		"lynis_suppressed_findings_total 5\n",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+
+	// totals of every output agree
+	if summary := marked.Summary(); summary.Warnings != 0 ||
+		summary.Suggestions != 4 {
+		t.Errorf("summary has %d warnings %d suggestions wanted %d %d",
+			summary.Warnings, summary.Suggestions, 0, 4)
+	}
+	text := &bytes.Buffer{}
+	influx := &bytes.Buffer{}
+	if err := marked.SerializeForText(text); err != nil {
+		t.Fatalf("error writting text: %s", err)
+	}
+	if err := marked.SerializeForInflux(influx); err != nil {
+		t.Fatalf("error writting influx: %s", err)
+	}
+	if !strings.Contains(text.String(), "Warnings:        0\n") ||
+		!strings.Contains(text.String(), "Suggestions:     4\n") {
+		t.Errorf("text has wrong totals:\n%s", text)
+	}
+	if !strings.Contains(influx.String(), "warnings=0i,suggestions=4i") {
+		t.Errorf("influx has wrong totals:\n%s", influx)
+	}
+	marked.DateTimeEnd = "2022-04-05T13:40:19Z"
+	trend, err := lynis.Analyze([]*lynis.Report{marked})
+	if err != nil || len(trend.Points) != 1 ||
+		trend.Points[0].Warnings != 0 || trend.Points[0].Suggestions != 4 {
+		t.Errorf("trend has wrong totals %+v. %v", trend.Points, err)
+	}
+
+	// suppressions only apply to matching hosts
+	report.Hostname = "db1"
+	suppressed, _ = suppressions.Apply(report, now, false)
+	tees, _ = suppressed.CreateTestElementElastics()
+	if len(tees) != 9 {
+		t.Errorf("kept %d elements wanted %d", len(tees), 9)
+	}
+}
+
+// test comparing findings of two reports
+func TestReportDiff(t *testing.T) {
+	old, err := lynis.CreateReport(strings.NewReader(testParse1 +
+		"hardening_index=60\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+	latest, err := lynis.CreateReport(strings.NewReader(
+		strings.Replace(testParse7, "warning[]=\n",
+			"warning[]=SSH-7408|Root login is permitted|-|-|\n", 1) +
+		"hardening_index=58\nhostname=web1\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	latest.Tests["SSH-7408"].Warnings[0].Severity = lynis.SEVERITY_CRITICAL
+	diff := lynis.Diff(old, latest)
+	added, resolved, persisting := diff.Count()
+	if added != 1 || resolved != 5 || persisting != 4 {
+		t.Errorf("diff has %d new %d resolved %d persisting wanted %d %d %d",
+			added, resolved, persisting, 1, 5, 4)
+	}
+	if diff.HardeningIndexDelta != -2 {
+		t.Errorf("hardening index delta %d wanted %d",
+			diff.HardeningIndexDelta, -2)
+	}
+	if len(diff.Changes) != 3 {
+		t.Errorf("diff has %d metadata changes wanted %d",
+			len(diff.Changes), 3)
+	}
+
+	// only new findings are written
+	data := &bytes.Buffer{}
+	if err := diff.Only(lynis.DIFF_NEW).SerializeForElasticSearch(data); err != nil {
+		t.Fatalf("error writing diff: %s", err)
+	}
+	var tee lynis.DiffElementElastic
+	if err := json.Unmarshal(data.Bytes(), &tee); err != nil {
+		t.Fatalf("error parsing diff element: %s", err)
+	}
+	if tee.Name != "SSH-7408" || tee.Change != lynis.DIFF_NEW ||
+		tee.Severity != lynis.SEVERITY_CRITICAL {
+		t.Errorf("diff element %s %s %s wanted %s %s %s", tee.Name,
+			tee.Change, tee.Severity, "SSH-7408", lynis.DIFF_NEW,
+			lynis.SEVERITY_CRITICAL)
+	}
+}
+
+// test storing reports in history
+func TestHistory(t *testing.T) {
+	history, err := lynis.OpenHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatalf("error opening history: %s", err)
+	}
+
+	// store three scans where NETW-3200 is resolved in the last scan
+	for i, day := range []string{"05", "06", "07"} {
+		input := testParse1 + "report_datetime_end=2022-04-" + day +
+			" 13:40:19\n"
+		if i == 2 {
+			input = strings.Replace(input, "suggestion[]=NETW-3200", "#", 1)
+		}
+		report, err := lynis.CreateReport(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("error parsing report: %s", err)
+		}
+		for j := 0; j < 2; j++ {
+			// saving the same scan again does not add to history
+			if _, err := history.Save(report); err != nil {
+				t.Fatalf("error saving report: %s", err)
+			}
+		}
+	}
+
+	host := "37feb2a24d03136df71ae200121805f5f4d526aa"
+	runs, err := history.Runs(host)
+	if err != nil {
+		t.Fatalf("error listing runs: %s", err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("history has %d runs wanted %d", len(runs), 3)
+	}
+	report, err := history.Load(runs[0])
+	if err != nil {
+		t.Fatalf("error loading report: %s", err)
+	}
+	if tees, _ := report.CreateTestElementElastics(); len(tees) != 9 ||
+		tees[0].LynisVersion != "3.0.7" {
+		t.Errorf("loaded report does not match stored report")
+	}
+
+	// raw values are stored with the report but are not part of its JSON
+	if services := report.Value("running_service"); len(services) != 10 {
+		t.Errorf("loaded report has %d running services wanted %d",
+			len(services), 10)
+	}
+	data, _ := json.Marshal(report)
+	if strings.Contains(string(data), `"values"`) {
+		t.Errorf("JSON of report contains raw values")
+	}
+
+	tests, err := history.TestHistory(host)
+	if err != nil {
+		t.Fatalf("error reading test history: %s", err)
+	}
+	for _, th := range tests {
+		if th.Name != "NETW-3200" {
+			continue
+		}
+		if th.Runs != 2 || th.Current || !th.LastSeen.Equal(runs[1].Time) ||
+			!th.FirstSeen.Equal(runs[0].Time) {
+			t.Errorf("unexpected history for NETW-3200 %+v", th)
+		}
+	}
+
+	// prune all but the latest report
+	removed, err := history.Prune(1, 0, time.Now())
+	if err != nil || removed != 2 {
+		t.Errorf("pruned %d reports wanted %d. %v", removed, 2, err)
+	}
+}
+
+// test analyzing trend of a series of reports
+func TestTrend(t *testing.T) {
+	// NETW-3200 is remediated after one day twice and comes back each time
+	reports := make([]*lynis.Report, 0)
+	for _, scan := range []struct {
+		day     string
+		finding bool
+	}{{"09", true}, {"05", true}, {"06", false}, {"07", true}, {"08", false}} {
+		input := testParse1 + "report_datetime_end=2022-04-" + scan.day +
+			" 13:40:19\n"
+		if !scan.finding {
+			input = strings.Replace(input, "suggestion[]=NETW-3200", "#", 1)
+		}
+		report, err := lynis.CreateReport(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("error parsing report: %s", err)
+		}
+		reports = append(reports, report)
+	}
+
+	trend, err := lynis.Analyze(reports)
+	if err != nil {
+		t.Fatalf("error analyzing reports: %s", err)
+	}
+	if len(trend.Points) != 5 || trend.Points[0].Suggestions != 5 ||
+		trend.Points[1].Suggestions != 4 {
+		t.Errorf("unexpected trend points")
+	}
+
+	for _, tt := range trend.Tests {
+		switch tt.Name {
+		case "NETW-3200":
+			if tt.Remediations != 2 || !tt.Flapping || !tt.Current ||
+				tt.Appearances != 3 || tt.MeanTimeToRemediate != 24*60*60 {
+				t.Errorf("unexpected trend for NETW-3200 %+v", tt)
+			}
+		case "NETW-2706":
+			if tt.Remediations != 0 || tt.Flapping || tt.Reports != 5 {
+				t.Errorf("unexpected trend for NETW-2706 %+v", tt)
+			}
+		}
+	}
+
+	data := &bytes.Buffer{}
+	if err := trend.SerializeForCSV(data, true); err != nil {
+		t.Fatalf("error writing trend: %s", err)
+	}
+	if !strings.Contains(data.String(), "NETW-3200,3,3,2,86400,true,true\n") {
+		t.Errorf("csv missing NETW-3200 trend:\n%s", data)
+	}
+
+	// reports of different hosts are not mixed
+	other, _ := lynis.CreateReport(strings.NewReader(strings.Replace(
+		testParse1, "hostid=37feb2a2", "hostid=00000000", 1)))
+	if _, err := lynis.Analyze(append(reports, other)); err == nil {
+		t.Errorf("expected error analyzing reports of two hosts")
+	}
+}
+
+// test evaluating report against policy
+func TestPolicy(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1 +
+		"hardening_index=70\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	// report without thresholds always complies
+	policy := lynis.NewPolicy()
+	if policy.Enabled() || len(policy.Evaluate(report)) != 0 {
+		t.Errorf("empty policy should not have violations")
+	}
+
+	policy.MaxWarnings = 4
+	policy.MaxSuggestions = 4
+	policy.MinHardeningIndex = 75
+	policy.FailOnTests = []string{"NETW-32*", "AUTH-9286"}
+	violations := policy.Evaluate(report)
+	rules := make([]string, 0)
+	for _, v := range violations {
+		rules = append(rules, v.Rule)
+	}
+	expected := []string{"max-suggestions 4", "min-hardening-index 75",
+		"fail-on-test NETW-3200", "fail-on-test NETW-3201",
+		"fail-on-test NETW-3202", "fail-on-test NETW-3203"}
+	if strings.Join(rules, ",") != strings.Join(expected, ",") {
+		t.Errorf("violated rules %v wanted %v", rules, expected)
+	}
+
+	// suppressed findings are not counted
+	policy = lynis.NewPolicy()
+	policy.FailOn = []string{"warning"}
+	suppressions, err := lynis.LoadSuppressions(strings.NewReader(
+		`{"suppressions": [{"test": "NETW-27*", "justification": "x", "owner": "ops", "expires": "2999-01-01"}]}`))
+	if err != nil {
+		t.Fatalf("error loading suppressions: %s", err)
+	}
+	marked, _ := suppressions.Apply(report, time.Now(), true)
+	if violations := policy.Evaluate(marked); len(violations) != 0 {
+		t.Errorf("unexpected violations %+v", violations[0])
+	}
+
+	policy.FailOn = []string{"severe"}
+	if err := policy.Validate(); err == nil {
+		t.Errorf("expected error validating policy")
+	}
+
+	// findings that are filtered from the output still violate the policy
+	t.Cleanup(func() {
+		addOptions(flag.NewFlagSet("defaults", flag.ContinueOnError))
+	})
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lynis-report.dat")
+	if err := os.WriteFile(path, []byte(testParse1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	flags := flag.NewFlagSet("policy", flag.ContinueOnError)
+	addOptions(flags)
+	if err := flags.Parse([]string{"-r", path, "--max-warnings", "0",
+		"--include", "type=suggestion",
+		"-s", "json=" + filepath.Join(dir, "report.json")}); err != nil {
+		t.Fatalf("error parsing options: %s", err)
+	}
+	if code := processReport(nil); code != ERR_POLICY {
+		t.Errorf("processing report exited with %d wanted %d", code,
+			ERR_POLICY)
+	}
+}
+
+// test evaluating rules on report
+func TestRules(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1 +
+		"hardening_index=82\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	rules, err := lynis.LoadRules(strings.NewReader(`{"rules": [
+	{"name": "netw", "expr": "count(warnings where test =~ \"NETW-27*\") == 0 && hardening_index >= 80", "message": "no network warnings"},
+	{"name": "telnet", "expr": "service \"telnet\" not in running_service"},
+	{"name": "getty", "expr": "count(running_service where value =~ \"getty*\") >= 4 || hostname == \"web1\""},
+	{"name": "index", "expr": "hardening_index > 90"},
+	{"name": "list", "expr": "running_service == \"cups\""}
+	]}`))
+	if err != nil {
+		t.Fatalf("error loading rules: %s", err)
+	}
+
+	results := rules.Evaluate(report)
+	expected := []struct {
+		pass    bool
+		message string
+	}{
+		{false, "no network warnings"},
+		{true, ""},
+		{true, ""},
+		{false, "hardening_index > 90"},
+		{false, ""},
+	}
+	for i, result := range results {
+		if result.Pass != expected[i].pass ||
+			result.Message != expected[i].message {
+			t.Errorf("unexpected result %+v", result)
+		}
+	}
+	if len(results[4].Error) < 1 {
+		t.Errorf("expected error comparing list of values")
+	}
+
+	// rules that can not be evaluated exit differently than failed rules
+	path := filepath.Join(t.TempDir(), "lynis-report.dat")
+	if err := os.WriteFile(path, []byte(testParse1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := runEvaluate([]string{"-r", path, "-e",
+		"hardening_index > 90"}); code != ERR_POLICY {
+		t.Errorf("evaluate exited with %d wanted %d", code, ERR_POLICY)
+	}
+	if code := runEvaluate([]string{"-r", path, "-e", "hardening_index > 90",
+		"-e", `running_service == "cups"`}); code != ERR_INVALIDOPT {
+		t.Errorf("evaluate exited with %d wanted %d", code, ERR_INVALIDOPT)
+	}
+
+	// invalid expressions fail to compile
+	for _, invalid := range []string{
+		`hardening_index >=`,
+		`count(warnings where test =~ "SSH-*"`,
+		`hostname ~ "("`,
+		`hostname == "web1" )`,
+	} {
+		if _, err := lynis.ParseExpr(invalid); err == nil {
+			t.Errorf("expected error parsing %s", invalid)
+		}
+	}
+}
+
+// test mapping tests to compliance controls
+func TestCompliance(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1 +
+		"tests_executed=NETW-3200|TIME-3104|SSH-7408|\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	// flattened findings have controls of built in mapping
+	tees, _ := report.CreateTestElementElastics()
+	for _, tee := range tees {
+		if tee.Name == "NETW-3200" &&
+			strings.Join(tee.Controls, ",") != "cis:4.8,iso27001:A.8.9,iso27001:A.8.20,nist-800-53:CM-7,pci-dss:2.2.4" {
+			t.Errorf("unexpected controls %v", tee.Controls)
+		}
+	}
+
+	// user mapping replaces controls of the same tests
+	mapping, err := lynis.LoadComplianceMapping(strings.NewReader(`{
+	"tests": {"NETW-27*": {"cis": ["12.2"]}, "NETW-3200": {"cis": ["4.1"]}}
+	}`))
+	if err != nil {
+		t.Fatalf("error loading mapping: %s", err)
+	}
+	merged := lynis.DefaultComplianceMapping()
+	merged.Merge(mapping)
+
+	cr, err := merged.Evaluate(report, "cis")
+	if err != nil {
+		t.Fatalf("error evaluating compliance: %s", err)
+	}
+	status := make(map[string]*lynis.ControlResult)
+	for _, c := range cr.Controls {
+		status[c.Control] = c
+	}
+	if c := status["4.8"]; c == nil || c.Status != lynis.CONTROL_NOT_TESTED ||
+		strings.Join(c.Tests, ",") != "BOOT-5264" {
+		t.Errorf("NETW-3200 should be removed from control 4.8 %+v", c)
+	}
+	if c := status["4.1"]; c == nil || c.Status != lynis.CONTROL_FAIL {
+		t.Errorf("control 4.1 should fail %+v", c)
+	}
+	if c := status["12.2"]; c == nil || c.Status != lynis.CONTROL_FAIL ||
+		len(c.Failed) != 4 {
+		t.Errorf("control 12.2 should fail with 4 tests %+v", c)
+	}
+	if c := status["8.4"]; c == nil || c.Status != lynis.CONTROL_PASS {
+		t.Errorf("control 8.4 should pass %+v", c)
+	}
+	if c := status["10.1"]; c == nil || c.Status != lynis.CONTROL_NOT_TESTED {
+		t.Errorf("control 10.1 should not be tested %+v", c)
+	}
+
+	if _, err := merged.Evaluate(report, "sox"); err == nil {
+		t.Errorf("expected error evaluating unknown framework")
+	}
+}
+
+// test adding details of tests from catalogue
+func TestCatalogue(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	test := report.Tests["NETW-3200"]
+	if test.Category != "Networking" ||
+		test.Title != "Determine available network protocols" ||
+		test.Reference != "https://cisofy.com/lynis/controls/NETW-3200/" {
+		t.Errorf("unexpected details for NETW-3200 %+v", test)
+	}
+	tees, _ := report.CreateTestElementElastics()
+	for _, tee := range tees {
+		if tee.Category != "Networking" {
+			t.Errorf("unexpected category %s for %s", tee.Category, tee.Name)
+		}
+	}
+
+	// update catalogue from Lynis test files
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "include"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "include", "tests_custom_checks"),
+		[]byte(`    Register --test-no NETW-3200 --weight L --network NO --category security --description "Determine protocols"
+    Register --test-no CUST-1000 --os Linux --weight L --network NO --category security --description "Custom check"
+    LogText "Register --test-no"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests, err := lynis.ParseLynisTests(dir)
+	if err != nil {
+		t.Fatalf("error parsing Lynis tests: %s", err)
+	}
+	if len(tests.Tests) != 2 || tests.Categories["CUST"] != "Custom checks" ||
+		tests.Categories["NETW"] != "Networking" {
+		t.Errorf("unexpected catalogue %+v", tests)
+	}
+
+	catalogue := lynis.DefaultCatalogue()
+	catalogue.Merge(tests)
+	lynis.SetCatalogue(catalogue)
+	defer lynis.SetCatalogue(lynis.DefaultCatalogue())
+	if info := catalogue.Lookup("CUST-1000"); info.Title != "Custom check" ||
+		info.Category != "Custom checks" {
+		t.Errorf("unexpected details for CUST-1000 %+v", info)
+	}
+	if title := lynis.NewTest("NETW-3200", report).Title; title != "Determine protocols" {
+		t.Errorf("unexpected title for NETW-3200 %s", title)
+	}
+}
+
+// test rating severity of findings and risk score
+func TestSeverity(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1 +
+		"warning[]=PKGS-7392|Found vulnerable packages|-|-|\n" +
+		"suggestion[]=PKGS-7392|Update vulnerable packages|-|-|\n" +
+		"suggestion[]=SSH-7408|Consider hardening SSH configuration|-|-|\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	// override, category and type decide severity
+	severity := func(test string, typ string) string {
+		for _, f := range report.Tests[test].Findings() {
+			if f.Type == typ {
+				return f.Severity()
+			}
+		}
+		return ""
+	}
+	for _, c := range []struct {
+		test, typ, severity string
+	}{
+		{"PKGS-7392", "warning", lynis.SEVERITY_CRITICAL},
+		{"PKGS-7392", "suggestion", lynis.SEVERITY_LOW},
+		{"SSH-7408", "suggestion", lynis.SEVERITY_MEDIUM},
+		{"NETW-2706", "warning", lynis.SEVERITY_MEDIUM},
+		{"NETW-3200", "suggestion", lynis.SEVERITY_LOW},
+	} {
+		if s := severity(c.test, c.typ); s != c.severity {
+			t.Errorf("%s %s has severity %s wanted %s", c.test, c.typ, s,
+				c.severity)
+		}
+	}
+
+	// critical 10 + medium 3 + 4 medium warnings 12 + 6 low suggestions 6
+	if score := report.RiskScore(); score != 31 {
+		t.Errorf("risk score %d wanted %d", score, 31)
+	}
+
+	// filter by severity
+	f, err := lynis.ParseFilter("severity>=medium")
+	if err != nil {
+		t.Fatalf("error parsing filter: %s", err)
+	}
+	tees, _ := report.Filter(f).CreateTestElementElastics()
+	if len(tees) != 6 {
+		t.Errorf("kept %d elements wanted %d", len(tees), 6)
+	}
+
+	// policy fails on severity and risk score
+	policy := lynis.NewPolicy()
+	policy.FailOn = []string{lynis.SEVERITY_HIGH}
+	policy.MaxRiskScore = 31
+	if violations := policy.Evaluate(report); len(violations) != 1 ||
+		violations[0].Rule != "fail-on high" {
+		t.Errorf("unexpected violations %v", violations)
+	}
+
+	// user model replaces entries of built in model
+	model, err := lynis.LoadSeverityModel(strings.NewReader(
+		`{"overrides": {"NETW-*": {"warning": "info"}}, "scores": {"critical": 100}}`))
+	if err != nil {
+		t.Fatalf("error loading severity model: %s", err)
+	}
+	merged := lynis.DefaultSeverityModel()
+	merged.Merge(model)
+	lynis.SetSeverityModel(merged)
+	defer lynis.SetSeverityModel(lynis.DefaultSeverityModel())
+	if s := merged.Severity("NETW-2706", "warning"); s != lynis.SEVERITY_INFO {
+		t.Errorf("NETW-2706 has severity %s wanted %s", s, lynis.SEVERITY_INFO)
+	}
+	if s := merged.Severity("NETW-2709", "suggestion"); s != lynis.SEVERITY_LOW {
+		t.Errorf("NETW-2709 suggestion has severity %s wanted %s", s,
+			lynis.SEVERITY_LOW)
+	}
+	if _, err := lynis.LoadSeverityModel(strings.NewReader(
+		`{"types": {"warning": "severe"}}`)); err == nil {
+		t.Errorf("expected error loading invalid severity model")
+	}
+}
+
+// test parsing scan metadata into summary
+func TestReportSummary(t *testing.T) {
+	input := testParse1 + `tests_executed=NETW-3200|NETW-2706|SSH-7408|
+tests_skipped=HTTP-6622|
+lynis_tests_done=262
+finish=true
+hardening_index=64
+`
+	data := &bytes.Buffer{}
+	report, err := lynis.Process(strings.NewReader(input), data,
+		&lynis.FormatElasticJSON{})
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	if len(report.TestsExecuted) != 3 || len(report.TestsSkipped) != 1 ||
+		report.LynisTestsDone != 262 || !report.Finished ||
+		report.Auditor != "[Not Specified]" || report.VersionMajor != 1 {
+		t.Errorf("unexpected scan metadata %+v", report)
+	}
+
+	// summary is the first document
+	var summary lynis.Summary
+	line, _, _ := strings.Cut(data.String(), "\n")
+	if err := json.Unmarshal([]byte(line), &summary); err != nil {
+		t.Fatalf("error parsing summary: %s", err)
+	}
+	if summary.Type != "summary" || summary.Warnings != 4 ||
+		summary.Suggestions != 5 || summary.HardeningIndex != 64 ||
+		summary.TestsExecuted != 3 || summary.ReportVersion != "1.0" ||
+		summary.Severities[lynis.SEVERITY_MEDIUM] != 4 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	// tests only holding details or manual checks are not counted
+	report, err = lynis.CreateReport(strings.NewReader(testParse1 +
+		"details[]=SSH-7408|sshd|\nmanual[]=AUTH-9308:01\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+	if tests := report.Summary().Tests; tests != 8 {
+		t.Errorf("summary has %d tests wanted %d", tests, 8)
+	}
+
+	// unknown report format is refused
+	_, err = lynis.CreateReport(strings.NewReader(
+		strings.Replace(testParse1, "report_version_major=1",
+			"report_version_major=2", 1)))
+	if err == nil {
+		t.Errorf("expected error parsing report format version 2")
+	}
+}
+
+func TestInventory(t *testing.T) {
+	input := testParse1 + `os=Linux
+os_name=Debian
+os_version=11
+os_kernel_version=5.10.0
+domainname=example.com
+network_ipv4_address[]=127.0.0.1
+network_ipv4_address[]=192.168.1.20
+network_mac_address[]=52:54:00:12:34:56
+installed_packages_array=|adduser,3.118|apt,2.2.4|bash,5.1-2|
+running_service[]=cron
+running_service[]=sshd
+boot_loader=GRUB2
+cpu_pae=1
+`
+	report, err := lynis.CreateReport(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	inv := report.Inventory()
+	if inv.OS != "Linux" || inv.OSName != "Debian" || inv.OSVersion != "11" ||
+		inv.KernelVersion != "5.10.0" || inv.Domainname != "example.com" ||
+		inv.BootLoader != "GRUB2" || !inv.CPUPAE || inv.CPUNX ||
+		inv.Hostname != report.Hostname {
+		t.Errorf("unexpected inventory %+v", inv)
+	}
+	if len(inv.IPv4Addresses) != 2 || inv.IPv4Addresses[1] != "192.168.1.20" ||
+		len(inv.MACAddresses) != 1 || len(inv.RunningServices) != 12 ||
+		inv.RunningServices[11] != "sshd" {
+		t.Errorf("unexpected inventory lists %+v", inv)
+	}
+	if len(inv.Packages) != 3 || inv.Packages[2].Name != "bash" ||
+		inv.Packages[2].Version != "5.1-2" {
+		t.Errorf("unexpected packages %+v", inv.Packages)
+	}
+
+	data := &bytes.Buffer{}
+	if err := lynis.SerializeInventoriesForCSV(data,
+		[]*lynis.Inventory{inv}); err != nil {
+		t.Fatalf("error writting csv: %s", err)
+	}
+	rows, err := csv.NewReader(data).ReadAll()
+	if err != nil {
+		t.Fatalf("error reading csv: %s", err)
+	}
+	if len(rows) != 2 || rows[0][0] != "hostname" ||
+		rows[1][18] != "127.0.0.1;192.168.1.20" || rows[1][26] != "3" {
+		t.Errorf("unexpected csv %v", rows)
+	}
+}
+
+func TestPackages(t *testing.T) {
+	input := testParse1 + `os_name=Debian
+os_version=11
+package_manager[]=dpkg
+installed_packages_array=|adduser,3.118|openssl,1.1.1n-0|bash,5.1-2|
+vulnerable_packages_found=1
+vulnerable_package[]=openssl
+`
+	report, err := lynis.CreateReport(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	packages := report.Packages()
+	if !packages.VulnerableFound || len(packages.Installed) != 3 ||
+		len(packages.Vulnerable) != 1 {
+		t.Fatalf("unexpected packages %+v", packages)
+	}
+	if v := packages.Vulnerable[0]; v.Name != "openssl" ||
+		v.Version != "1.1.1n-0" || !packages.Installed[1].Vulnerable ||
+		packages.Installed[0].Vulnerable {
+		t.Errorf("unexpected vulnerable package %+v", v)
+	}
+
+	// CycloneDX is keyed by host ID and stable for the same report
+	data := &bytes.Buffer{}
+	if err := packages.SerializeForCycloneDX(data); err != nil {
+		t.Fatalf("error writting CycloneDX: %s", err)
+	}
+	var bom lynis.CycloneDX
+	if err := json.Unmarshal(data.Bytes(), &bom); err != nil {
+		t.Fatalf("error parsing CycloneDX: %s", err)
+	}
+	if bom.BOMFormat != "CycloneDX" || bom.SpecVersion != "1.5" ||
+		bom.Metadata.Component.BOMRef != report.HostID ||
+		len(bom.Components) != 3 ||
+		bom.Components[1].PURL != "pkg:deb/debian/openssl@1.1.1n-0" ||
+		len(bom.Components[1].Properties) != 1 {
+		t.Errorf("unexpected CycloneDX %s", data.String())
+	}
+	if bom.SerialNumber != packages.CycloneDX().SerialNumber ||
+		!strings.HasPrefix(bom.SerialNumber, "urn:uuid:") {
+		t.Errorf("unexpected serial number %s", bom.SerialNumber)
+	}
+
+	// versions are percent-encoded in package URLs
+	report, err = lynis.CreateReport(strings.NewReader(testParse1 +
+		"os_name=Debian\npackage_manager[]=dpkg\n" +
+		"installed_packages_array=|libfoo,1:2.3+dfsg-1~deb11u1|\n"))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+	bom = *report.Packages().CycloneDX()
+	if purl := bom.Components[0].PURL; purl !=
+		"pkg:deb/debian/libfoo@1%3A2.3%2Bdfsg-1~deb11u1" {
+		t.Errorf("unexpected package URL %s", purl)
+	}
+}
+
+func TestReportEntries(t *testing.T) {
+	input := testParse1 + `manual[]=AUTH-9308:01
+manual[]=Check physical security of the system
+details[]=SSH-7408|sshd|desc:sshd option PermitRootLogin;field:PermitRootLogin;prefval:NO;value:YES;|
+details[]=HTTP-6622|nginx|
+deleted_file[]=/tmp/.x11-unix.lock
+usb_storage=1
+firewall_active=1
+firewall_software[]=iptables
+firewall_software[]=nftables
+plugin_processes_allusers=842
+`
+	report, err := lynis.CreateReport(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	ssh := report.Tests["SSH-7408"]
+	if ssh == nil || len(ssh.Details) != 1 ||
+		ssh.Details[0].Service != "sshd" ||
+		ssh.Details[0].Description != "sshd option PermitRootLogin" ||
+		ssh.Details[0].Fields["value"] != "YES" {
+		t.Fatalf("unexpected details %+v", ssh)
+	}
+	if auth := report.Tests["AUTH-9308"]; auth == nil ||
+		len(auth.Manual) != 1 || auth.Manual[0].Message != "AUTH-9308:01" {
+		t.Errorf("unexpected manual check %+v", auth)
+	}
+	if len(report.Manual) != 1 || len(report.DeletedFiles) != 1 ||
+		report.USBStorage != "1" ||
+		len(report.Firewall["software"]) != 2 ||
+		report.Plugins["processes_allusers"][0] != "842" {
+		t.Errorf("unexpected report entries %+v", report)
+	}
+	if v := report.Value("firewall_software[]"); len(v) != 2 {
+		t.Errorf("unexpected firewall value %v", v)
+	}
+
+	// entries are flattened as additional types
+	tees, _ := report.CreateTestElementElastics()
+	types := make(map[string]int)
+	for _, tee := range tees {
+		types[tee.Type]++
+	}
+	if types["warning"] != 4 || types["suggestion"] != 5 ||
+		types["manual"] != 2 || types["detail"] != 2 ||
+		types["deleted_file"] != 1 || types["usb_storage"] != 1 ||
+		types["firewall"] != 3 || types["plugin"] != 1 {
+		t.Errorf("unexpected element types %v", types)
+	}
+
+	// details and other values are not findings
+	warnings, suggestions := report.Count()
+	if warnings != 4 || suggestions != 5 {
+		t.Errorf("unexpected count %d warnings %d suggestions", warnings,
+			suggestions)
+	}
+	data := &bytes.Buffer{}
+	if err := report.SerializeForOCSF(data); err != nil {
+		t.Fatalf("error writting OCSF: %s", err)
+	}
+	if lines := strings.Count(data.String(), "\n"); lines != 11 {
+		t.Errorf("expected 11 OCSF findings got %d", lines)
+	}
+
+	// entries are filtered the same as findings
+	count := func(r *lynis.Report) map[string]int {
+		tees, _ := r.CreateTestElementElastics()
+		types := make(map[string]int)
+		for _, tee := range tees {
+			types[tee.Type]++
+		}
+		return types
+	}
+	include, _ := lynis.ParseFilter("type=warning")
+	types = count(report.Filter(lynis.IncludeExclude(
+		[]lynis.Filter{include}, nil)))
+	if len(types) != 1 || types["warning"] != 4 {
+		t.Errorf("include type=warning kept element types %v", types)
+	}
+	exclude, _ := lynis.ParseFilter("test=firewall_*")
+	types = count(report.Filter(lynis.IncludeExclude(nil,
+		[]lynis.Filter{exclude})))
+	if types["firewall"] != 0 || types["detail"] != 2 ||
+		types["plugin"] != 1 || types["deleted_file"] != 1 {
+		t.Errorf("exclude test=firewall_* kept element types %v", types)
+	}
+
+	// entries can be suppressed
+	suppressions, err := lynis.LoadSuppressions(strings.NewReader(
+		`{"suppressions": [{"test": "firewall_software",
+		"message": "nftables", "justification": "Migrating to nftables",
+		"owner": "ops", "expires": "2099-12-31"}]}`))
+	if err != nil {
+		t.Fatalf("error loading suppressions: %s", err)
+	}
+	suppressed, _ := suppressions.Apply(report, time.Now(), false)
+	if v := suppressed.Firewall["software"]; len(v) != 1 || v[0] != "iptables" {
+		t.Errorf("unexpected firewall software after suppression %v", v)
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	input := strings.Replace(testParse1, "plugins_enabled=0",
+		"plugins_enabled=1", 1) + `plugin_directory=/usr/share/lynis/plugins
+plugin_enabled_phase1[]=pam|1.0.4|
+plugin_enabled_phase1[]=systemd|1.0.2|
+plugin_enabled_phase2[]=processes|
+plugin_processes_allusers=842
+`
+	report, err := lynis.CreateReport(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+
+	if !report.PluginsEnabled || len(report.EnabledPlugins) != 3 {
+		t.Fatalf("unexpected plugins %+v", report.EnabledPlugins)
+	}
+	if p := report.EnabledPlugins[1]; p.Name != "systemd" ||
+		p.Version != "1.0.2" || p.Phase != 1 {
+		t.Errorf("unexpected plugin %+v", p)
+	}
+	if p := report.EnabledPlugins[2]; p.Name != "processes" ||
+		p.Version != "" || p.Phase != 2 {
+		t.Errorf("unexpected plugin %+v", p)
+	}
+	if report.Plugins["directory"][0] != "/usr/share/lynis/plugins" ||
+		report.Plugins["processes_allusers"][0] != "842" {
+		t.Errorf("unexpected plugin values %v", report.Plugins)
+	}
+
+	// plugin values are available to rules
+	rules := &lynis.Rules{Rules: []*lynis.Rule{
+		{Name: "pam", Expr: `plugin "pam" in plugin_enabled_phase1`},
+		{Name: "enabled", Expr: `plugins_enabled == 1`},
+	}}
+	for _, result := range rules.Evaluate(report) {
+		if !result.Pass {
+			t.Errorf("expected rule %s to pass %+v", result.Name, result)
+		}
+	}
+
+	// malformed plugins are an error
+	_, err = lynis.CreateReport(strings.NewReader(testParse1 +
+		"plugin_enabled_phase1[]=|\n"))
+	if err == nil {
+		t.Errorf("expected error parsing plugin without name")
+	}
+}
+
+func TestRegisterKeyHandler(t *testing.T) {
+	// custom test findings are added as warnings
+	err := lynis.RegisterKeyHandler("custom_check[]",
+		func(r *lynis.Report, key, value string) error {
+			id, message, ok := strings.Cut(value, "|")
+			if !ok {
+				return errors.New("custom check without message")
+			}
+			lynis.AddWarning(r.AddTest(id), &lynis.TestElement{
+				Message: message,
+			})
+			r.AddValue(key, id)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("error registering handler: %s", err)
+	}
+
+	// prefixes handle a family of keys, exact keys take precedence
+	site := make(map[string]string)
+	lynis.RegisterKeyHandler("site_*",
+		func(r *lynis.Report, key, value string) error {
+			site[key] = value
+			return nil
+		})
+	lynis.RegisterKeyHandler("site_owner",
+		func(r *lynis.Report, key, value string) error {
+			site[key] = strings.ToUpper(value)
+			return nil
+		})
+
+	report, err := lynis.CreateReport(strings.NewReader(testParse1 +
+		`custom_check[]=CUST-0001|Backup agent is not running
+custom_check[]=CUST-0002|Disk encryption is disabled
+site_owner=ops
+site_rack=b12
+`))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+	if cust := report.Tests["CUST-0002"]; cust == nil ||
+		len(cust.Warnings) != 1 ||
+		cust.Warnings[0].Message != "Disk encryption is disabled" {
+		t.Errorf("unexpected custom test %+v", cust)
+	}
+	if v := report.Value("custom_check"); len(v) != 2 || v[1] != "CUST-0002" {
+		t.Errorf("unexpected custom values %v", v)
+	}
+	if site["site_owner"] != "OPS" || site["site_rack"] != "b12" {
+		t.Errorf("unexpected site values %v", site)
+	}
+
+	// errors of handlers fail parsing
+	_, err = lynis.CreateReport(strings.NewReader(testParse1 +
+		"custom_check[]=CUST-0003\n"))
+	if err == nil {
+		t.Errorf("expected error from custom handler")
+	}
+
+	// invalid patterns are refused
+	for _, pattern := range []string{"", "*", "[]", "a=b", "a*b"} {
+		if lynis.RegisterKeyHandler(pattern,
+			func(*lynis.Report, string, string) error { return nil }) == nil {
+			t.Errorf("expected error registering pattern %q", pattern)
+		}
+	}
+	if lynis.RegisterKeyHandler("custom", nil) == nil {
+		t.Errorf("expected error registering nil handler")
+	}
+}
+
+func TestParseLog(t *testing.T) {
+	log := `2022-04-05 13:36:19 Starting Lynis 3.0.7 with PID 4211, build date 2022-01-18
+2022-04-05 13:36:20 ===---------------------------------------------------------------===
+2022-04-05 13:36:20 Performing test ID NETW-3200 (Determine available network protocols)
+2022-04-05 13:36:20 Test: checking dccp
+2022-04-05 13:36:23 Result: found dccp
+2022-04-05 13:36:24 ===---------------------------------------------------------------===
+2022-04-05 13:36:24 Skipped test HTTP-6622 (Check Apache installation)
+2022-04-05 13:36:24 Reason to skip: Apache not installed
+2022-04-05 13:36:24 ===---------------------------------------------------------------===
+2022-04-05 13:36:24 Performing test ID SSH-7408 (Check SSH specific defined options)
+2022-04-05 13:36:25 Result: sshd option PermitRootLogin found
+  continued line of previous entry
+2022-04-05 13:36:31 Lynis ended successfully.
+`
+	l, err := lynis.ParseLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("error parsing log: %s", err)
+	}
+	if len(l.Tests) != 3 {
+		t.Fatalf("expected 3 tests got %d", len(l.Tests))
+	}
+	netw := l.Tests["NETW-3200"]
+	if netw.Status != lynis.TEST_PERFORMED || netw.Duration != 4 ||
+		netw.Description != "Determine available network protocols" ||
+		len(netw.Results) != 1 || netw.Results[0] != "found dccp" {
+		t.Errorf("unexpected execution %+v", netw)
+	}
+	if http := l.Tests["HTTP-6622"]; http.Status != lynis.TEST_SKIPPED ||
+		http.Reason != "Apache not installed" || http.Duration != 0 {
+		t.Errorf("unexpected execution %+v", http)
+	}
+	if ssh := l.Tests["SSH-7408"]; ssh.Duration != 7 {
+		t.Errorf("unexpected execution %+v", ssh)
+	}
+
+	// executions are added to tests of the report and outputs
+	report, err := lynis.CreateReport(strings.NewReader(testParse1))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+	report.AddLog(l)
+	if performed, skipped := report.CountExecutions(); performed != 2 ||
+		skipped != 1 {
+		t.Errorf("unexpected executions %d performed %d skipped",
+			performed, skipped)
+	}
+	if report.Tests["NETW-3200"].Execution != netw {
+		t.Errorf("expected test to be linked to its execution")
+	}
+	filtered := report.Filter(lynis.FilterFunc(func(*lynis.Finding) bool {
+		return true
+	}))
+	tees, _ := filtered.CreateTestElementElastics()
+	for _, tee := range tees {
+		if tee.Name == "NETW-3200" && (tee.Status != lynis.TEST_PERFORMED ||
+			tee.Duration == nil || *tee.Duration != 4) {
+			t.Errorf("unexpected element %+v", tee)
+		}
+	}
+
+	// tests performed within a second have a duration of 0 seconds
+	quick, _ := lynis.ParseLog(strings.NewReader(
+		`2022-04-05 13:36:20 Performing test ID NETW-2706 (Check nameservers)
+2022-04-05 13:36:20 ===---------------------------------------------------------------===
+`))
+	report.AddLog(quick)
+	tees, _ = report.CreateTestElementElastics()
+	for _, tee := range tees {
+		if tee.Name != "NETW-2706" {
+			continue
+		}
+		data, _ := json.Marshal(tee)
+		if !strings.Contains(string(data), `"duration_seconds":0`) {
+			t.Errorf("element missing duration %s", data)
+		}
+	}
+
+	// executions are kept when stored as JSON
+	data := &bytes.Buffer{}
+	if err := json.NewEncoder(data).Encode(report); err != nil {
+		t.Fatalf("error serializing report: %s", err)
+	}
+	loaded, err := lynis.LoadReportJSON(data)
+	if err != nil {
+		t.Fatalf("error loading report: %s", err)
+	}
+	if e := loaded.Tests["NETW-3200"].Execution; e == nil || e.Duration != 4 {
+		t.Errorf("unexpected loaded execution %+v", e)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	reportFile := filepath.Join(dir, "lynis-report.dat")
+	argsFile := filepath.Join(dir, "args")
+	outputFile := filepath.Join(dir, "lynis.out")
+	jsonFile := filepath.Join(dir, "report.json")
+
+	// fake Lynis writes the report to the report file it is given
+	fake := `#!/bin/sh
+echo "$@" > ` + argsFile + `
+while [ $# -gt 0 ]; do
+	case "$1" in
+		--report-file) shift; report="$1" ;;
+	esac
+	shift
+done
+cat > "$report" <<'REPORT'
+` + testParse1 + `REPORT
+echo "Lynis audit complete"
+echo "notice from lynis" >&2
+`
+	if err := os.WriteFile(filepath.Join(dir, "lynis"), []byte(fake),
+		0755); err != nil {
+		t.Fatalf("error writting fake Lynis: %s", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// options of run are reset to their defaults after the test
+	t.Cleanup(func() {
+		addOptions(flag.NewFlagSet("defaults", flag.ContinueOnError))
+	})
+
+	code := runRun([]string{"-r", reportFile, "-s", "json=" + jsonFile,
+		"--profile", "/etc/lynis/custom.prf", "--cronjob",
+		"--lynis-output", outputFile})
+	if code != 0 {
+		t.Fatalf("expected exit code 0 got %d", code)
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	if strings.TrimSpace(string(args)) != "audit system --no-colors "+
+		"--report-file "+reportFile+" --profile /etc/lynis/custom.prf --cronjob" {
+		t.Errorf("unexpected Lynis arguments %s", args)
+	}
+	output, _ := os.ReadFile(outputFile)
+	if string(output) != "Lynis audit complete\nnotice from lynis\n" {
+		t.Errorf("unexpected Lynis output %q", output)
+	}
+
+	// report written by Lynis is processed and written to sinks
+	input, err := os.Open(jsonFile)
+	if err != nil {
+		t.Fatalf("error opening output: %s", err)
+	}
+	defer input.Close()
+	report, err := lynis.LoadReportJSON(input)
+	if err != nil {
+		t.Fatalf("error loading output: %s", err)
+	}
+	if warnings, suggestions := report.Count(); warnings != 4 ||
+		suggestions != 5 {
+		t.Errorf("unexpected output %d warnings %d suggestions", warnings,
+			suggestions)
+	}
+
+	// Lynis failing without writting a report is an error
+	failing := filepath.Join(dir, "failing")
+	if err := os.WriteFile(failing, []byte("#!/bin/sh\nexit 1\n"),
+		0755); err != nil {
+		t.Fatalf("error writting failing Lynis: %s", err)
+	}
+	code = runRun([]string{"-r", filepath.Join(dir, "missing.dat"),
+		"--lynis", failing})
+	if code != ERR_LYNIS {
+		t.Errorf("expected exit code %d got %d", ERR_LYNIS, code)
+	}
+	// options of the previous run are not kept
+	if len(sinkOpt) > 0 {
+		t.Errorf("options of previous run were kept %v", sinkOpt)
+	}
+}
+
+// test report values that do not belong to a test are pushed to Loki without
+// a category label
+func TestReportLokiEntries(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1 +
+		`firewall_active=1
+firewall_software[]=iptables
+plugin_processes_allusers=842
+deleted_file[]=/tmp/.x11-unix.lock
+`))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+	push, err := report.CreateLokiPush()
+	if err != nil {
+		t.Fatalf("error creating push: %s", err)
+	}
+
+	types := make(map[string]int)
+	for _, s := range push.Streams {
+		category, ok := s.Stream["category"]
+		switch s.Stream["type"] {
+		case "warning", "suggestion":
+			if category != "NETW" {
+				t.Errorf("stream category %s wanted %s", category, "NETW")
+			}
+		default:
+			if ok {
+				t.Errorf("unexpected category %s for %s", category,
+					s.Stream["type"])
+			}
+		}
+		types[s.Stream["type"]] += len(s.Values)
+	}
+	if len(push.Streams) != 5 || types["firewall"] != 2 ||
+		types["plugin"] != 1 || types["deleted_file"] != 1 {
+		t.Errorf("unexpected streams %v", types)
+	}
+}
+
+// test manual checks are suppressed the same when marked or removed
+func TestSuppressManual(t *testing.T) {
+	input := testParse1 + "manual[]=AUTH-9308:01\nmanual[]=AUTH-9308:02\n"
+	suppressions, err := lynis.LoadSuppressions(strings.NewReader(
+		`{"suppressions": [{"test": "AUTH-*", "message": ":01",
+		"justification": "Boot loader password is set by BIOS",
+		"owner": "ops", "expires": "2099-12-31"}]}`))
+	if err != nil {
+		t.Fatalf("error loading suppressions: %s", err)
+	}
+
+	report, _ := lynis.CreateReport(strings.NewReader(input))
+	marked, _ := suppressions.Apply(report, time.Now(), true)
+	manual := marked.Tests["AUTH-9308"].Manual
+	if len(manual) != 2 || !manual[0].Suppressed || manual[1].Suppressed {
+		t.Errorf("unexpected marked manual checks %+v %+v", manual[0],
+			manual[1])
+	}
+
+	report, _ = lynis.CreateReport(strings.NewReader(input))
+	removed, _ := suppressions.Apply(report, time.Now(), false)
+	manual = removed.Tests["AUTH-9308"].Manual
+	if len(manual) != 1 || manual[0].Message != "AUTH-9308:02" {
+		t.Errorf("unexpected remaining manual checks %+v", manual)
+	}
+}
+
+// test categories match by prefix and by catalogue name
+func TestCategoryNames(t *testing.T) {
+	report, err := lynis.CreateReport(strings.NewReader(testParse1))
+	if err != nil {
+		t.Fatalf("error parsing report: %s", err)
+	}
+	if category := report.Tests["NETW-3200"].Category; category != "Networking" {
+		t.Fatalf("unexpected category %s", category)
+	}
+
+	for _, expr := range []string{"category=NETW", "category=Networking",
+		"category=networking"} {
+		filter, err := lynis.ParseFilter(expr)
+		if err != nil {
+			t.Fatalf("error parsing filter %s: %s", expr, err)
+		}
+		if w, s := report.Filter(filter).Count(); w != 4 || s != 5 {
+			t.Errorf("filter %s matched %d warnings %d suggestions", expr,
+				w, s)
+		}
+	}
+	filter, _ := lynis.ParseFilter("category=Kernel")
+	if w, s := report.Filter(filter).Count(); w+s != 0 {
+		t.Errorf("filter category=Kernel matched %d findings", w+s)
+	}
+
+	for expr, want := range map[string]bool{
+		`count(findings where category == "NETW") == 9`:       true,
+		`count(findings where category == "Networking") == 9`: true,
+		`count(findings where category != "Networking") == 0`: true,
+		`count(findings where category =~ "Net*") == 9`:       true,
+		`count(findings where category ~ "^NE") == 9`:         true,
+		`count(findings where category == "Kernel") == 0`:     true,
+		`count(findings where category != "NETW") == 9`:       false,
+	} {
+		e, err := lynis.ParseExpr(expr)
+		if err != nil {
+			t.Fatalf("error parsing %s: %s", expr, err)
+		}
+		if got, err := e.Eval(report); err != nil || got != want {
+			t.Errorf("%s evaluated to %t wanted %t. %v", expr, got, want, err)
+		}
+	}
+}
@@ -17,17 +17,38 @@ import (
 	flag "github.com/spf13/pflag"
 	"lynisreport/lynis"
 	"os"
+	"time"
 )
 
 // Commandline Options
-var helpOpt bool         // option to print help menu for tool
-var repOpt string        // option for Lynis report location
-var logOpt string        // option for log location to output parsed data
-var fmtTimestampOpt bool // add timestamp to data
-var fmtJsonOpt bool      // option to output data as json
-var fmtYamlOpt bool      // option to output data as yaml
-var fmtNewLineOpt bool   // option to append newline at end of output
-var fmtElasticOpt bool   // option to output test info compatible to be ingetsted by Elasticsearch
+var helpOpt bool          // option to print help menu for tool
+var repOpt string         // option for Lynis report location
+var logInputOpt string    // option for Lynis log location to add test execution from
+var logOpt string         // option for log location to output parsed data
+var fmtTimestampOpt bool  // add timestamp to data
+var fmtJsonOpt bool       // option to output data as json
+var fmtYamlOpt bool       // option to output data as yaml
+var fmtNewLineOpt bool    // option to append newline at end of output
+var fmtElasticOpt bool    // option to output test info compatible to be ingetsted by Elasticsearch
+var fmtPromOpt bool       // option to output data as Prometheus metrics
+var fmtInfluxOpt bool     // option to output data as InfluxDB line protocol
+var fmtOCSFOpt bool       // option to output data as OCSF Compliance Findings
+var fmtTextOpt bool       // option to output data as human readable summary
+var sinkOpt []string      // option for sinks to write report to
+var includeOpt []string   // option for filters of findings to include
+var excludeOpt []string   // option for filters of findings to exclude
+var suppressOpt string    // option for suppressions file location
+var markSuppressOpt bool  // option to mark suppressed findings instead of removing them
+var historyOpt string     // option for directory to store history of reports in
+var historyKeepOpt int    // option for amount of reports to keep per host
+var historyMaxDaysOpt int // option for days to keep reports for
+var lokiOpt string        // option for Loki server URL to push test info to
+var lokiTenantOpt string  // option for Loki tenant
+var complianceOpt string  // option for compliance mapping file location
+var catalogueOpt string   // option for catalogue of tests file location
+var severityOpt string    // option for severity model file location
+
+var policyOpt = lynis.NewPolicy() // options for policy report must comply with
 
 const (
 	// Error values to be returned
@@ -36,54 +57,196 @@ const (
 	ERR_PROCCESS   int = 4
 	ERR_WRITELOG   int = 5
 	ERR_INVALIDOPT int = 6
+	ERR_PUSH       int = 7
+	ERR_LISTEN     int = 8
+	ERR_HISTORY    int = 9
+	ERR_POLICY     int = 10
+	ERR_LOGINPUT   int = 11
+	ERR_LYNIS      int = 12
+)
+
+const (
+	// Default directory to store history of reports in
+	HISTORY_DIR string = "/var/lib/lynisreport/history"
 )
 
 // Initalize command line options
 func init() {
-	flag.BoolVarP(&helpOpt,
+	addOptions(flag.CommandLine)
+}
+
+// Adds the command line options to the flag set, options are reset to their
+// defaults
+func addOptions(flags *flag.FlagSet) {
+	flags.BoolVarP(&helpOpt,
 		"help",
 		"h",
 		false,
 		"Print help menu")
-	flag.StringVarP(&repOpt,
+	flags.StringVarP(&repOpt,
 		"reportfile",
 		"r",
 		"/var/log/lynis-report.dat",
 		"Specify where to find the Lynis report file")
-	flag.StringVarP(&logOpt,
+	flags.StringVar(&logInputOpt,
+		"logfile-input",
+		"",
+		"Specify Lynis log file eg. /var/log/lynis.log to add execution and duration of tests from")
+	flags.StringVarP(&logOpt,
 		"logfile",
 		"l",
 		"",
 		"Specify where to log output of report. Default is to standard output")
-	flag.BoolVarP(&fmtTimestampOpt,
+	flags.BoolVarP(&fmtTimestampOpt,
 		"timestamp",
 		"t",
 		false,
 		"Prepend timestamp info before data output")
-	flag.BoolVarP(&fmtJsonOpt,
+	flags.BoolVarP(&fmtJsonOpt,
 		"json",
 		"j",
 		true,
 		"Output data in json(default output)")
-	flag.BoolVarP(&fmtYamlOpt,
+	flags.BoolVarP(&fmtYamlOpt,
 		"yaml",
 		"y",
 		false,
 		"Output data in yaml(not yet implemented)")
-	flag.BoolVarP(&fmtNewLineOpt,
+	flags.BoolVarP(&fmtNewLineOpt,
 		"newline",
 		"n",
 		false,
 		"Append new line character to end of output")
-	flag.BoolVarP(&fmtElasticOpt,
+	flags.BoolVarP(&fmtElasticOpt,
 		"elastic",
 		"e",
 		false,
 		"Output test data in multiple JSON objects to be ingested into Elasticsearch")
+	flags.BoolVarP(&fmtPromOpt,
+		"prometheus",
+		"p",
+		false,
+		"Output report as Prometheus metrics, log file is replaced atomically for the node_exporter textfile collector")
+	flags.BoolVarP(&fmtInfluxOpt,
+		"influx",
+		"i",
+		false,
+		"Output report as InfluxDB line protocol")
+	flags.BoolVar(&fmtOCSFOpt,
+		"ocsf",
+		false,
+		"Output test data in multiple OCSF Compliance Finding JSON objects")
+	flags.BoolVar(&fmtTextOpt,
+		"text",
+		false,
+		"Output report as a human readable summary")
+	flags.StringArrayVarP(&sinkOpt,
+		"sink",
+		"s",
+		nil,
+		"Write report to sink FORMAT[,MODIFIER...][=DESTINATION], can be repeated. Formats are json, elastic, prometheus, influx, ocsf, loki and text, modifiers are timestamp and newline. Destination is a file, - for standard output or the URL for loki")
+	flags.StringArrayVar(&includeOpt,
+		"include",
+		nil,
+		"Only output findings matching filter, can be repeated. Filters are type=TYPE, test=GLOB, category=NAME, severity=LEVEL, severity>=LEVEL, message~REGEX and details~REGEX")
+	flags.StringArrayVar(&excludeOpt,
+		"exclude",
+		nil,
+		"Remove findings matching filter from output, can be repeated")
+	flags.StringVar(&suppressOpt,
+		"suppressions",
+		"",
+		"Specify file of accepted risks to suppress findings with, the file must be JSON")
+	flags.BoolVar(&markSuppressOpt,
+		"mark-suppressed",
+		false,
+		"Mark suppressed findings as suppressed instead of removing them")
+	flags.StringVar(&historyOpt,
+		"history",
+		"",
+		"Store report in history directory eg. "+HISTORY_DIR)
+	flags.IntVar(&historyKeepOpt,
+		"history-keep",
+		0,
+		"Amount of reports to keep in history for each host. Default is all")
+	flags.IntVar(&historyMaxDaysOpt,
+		"history-max-days",
+		0,
+		"Days to keep reports in history for. Default is forever")
+	flags.StringArrayVar(&policyOpt.FailOn,
+		"fail-on",
+		nil,
+		"Exit with policy violation if report has findings of type warning or suggestion, or of severity info, low, medium, high or critical or more severe, can be repeated")
+	flags.IntVar(&policyOpt.MaxWarnings,
+		"max-warnings",
+		-1,
+		"Exit with policy violation if report has more warnings. Default is unlimited")
+	flags.IntVar(&policyOpt.MaxSuggestions,
+		"max-suggestions",
+		-1,
+		"Exit with policy violation if report has more suggestions. Default is unlimited")
+	flags.IntVar(&policyOpt.MinHardeningIndex,
+		"min-hardening-index",
+		0,
+		"Exit with policy violation if report has a lower hardening index")
+	flags.IntVar(&policyOpt.MaxRiskScore,
+		"max-risk-score",
+		-1,
+		"Exit with policy violation if report has a higher risk score. Default is unlimited")
+	flags.StringArrayVar(&policyOpt.FailOnTests,
+		"fail-on-test",
+		nil,
+		"Exit with policy violation if report has findings for test ID or glob pattern, can be repeated")
+	flags.StringVar(&complianceOpt,
+		"compliance-map",
+		"",
+		"Specify JSON file mapping tests to compliance controls, replaces built in mapping of the same tests")
+	flags.StringVar(&catalogueOpt,
+		"catalogue",
+		"",
+		"Specify JSON catalogue of test categories and titles, replaces built in details of the same tests")
+	flags.StringVar(&severityOpt,
+		"severity-model",
+		"",
+		"Specify JSON severity model, replaces built in severities and scores of the same entries")
+	flags.StringVar(&lokiOpt,
+		"loki",
+		"",
+		"Push test data to the Loki server at this URL eg. http://localhost:3100")
+	flags.StringVar(&lokiTenantOpt,
+		"loki-tenant",
+		"",
+		"Tenant to push test data to in Loki")
 }
 
 func main() {
 
+	// Run subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "exporter":
+			os.Exit(runExporter(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		case "history":
+			os.Exit(runHistory(os.Args[2:]))
+		case "trend":
+			os.Exit(runTrend(os.Args[2:]))
+		case "evaluate":
+			os.Exit(runEvaluate(os.Args[2:]))
+		case "compliance":
+			os.Exit(runCompliance(os.Args[2:]))
+		case "catalogue":
+			os.Exit(runCatalogue(os.Args[2:]))
+		case "inventory":
+			os.Exit(runInventory(os.Args[2:]))
+		case "packages":
+			os.Exit(runPackages(os.Args[2:]))
+		case "run":
+			os.Exit(runRun(os.Args[2:]))
+		}
+	}
+
 	// Parse command line args
 	flag.Parse()
 
@@ -92,26 +255,97 @@ func main() {
                 os.Exit(0)
         }
 
-	// set data formatters
-	var formatter lynis.OutputFormatter
-	if fmtYamlOpt {
-		fmt.Fprintf(os.Stderr,
-			"error: yaml output is not yet implemented\n")
-		os.Exit(ERR_INVALIDOPT)
-	} else if fmtElasticOpt {
-		formatter = &lynis.FormatElasticJSON{}
-	} else {
-		formatter = &lynis.FormatJSON{}
+	os.Exit(processReport(nil))
+}
+
+// Processes the report with the command line options and writes it to all
+// sinks, returns exit code. Produce is called to create the report once the
+// options are checked and before the report is read if it is not nil
+func processReport(produce func() int) int {
+	// set sinks to write report to
+	sinks := make([]*Sink, 0)
+	for _, spec := range sinkOpt {
+		sink, err := parseSink(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid sink %s. %s\n",
+				spec, err)
+			return ERR_INVALIDOPT
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) < 1 {
+		for _, spec := range legacySinks() {
+			sink, err := parseSink(spec)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s\n", err)
+				return ERR_INVALIDOPT
+			}
+			sinks = append(sinks, sink)
+		}
 	}
 
-	// add optional timestamp
-	if fmtTimestampOpt {
-		lynis.SetNext(formatter, &lynis.FormatTimestamp{})
+	// check policy options
+	if err := policyOpt.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
 	}
 
-	// add new line to end of output
-	if fmtNewLineOpt {
-		lynis.SetNext(formatter, &lynis.FormatNewLine{})
+	// add user mapping of tests to compliance controls
+	if len(complianceOpt) > 0 {
+		if err := readComplianceMapping(complianceOpt); err != nil {
+			fmt.Fprintf(os.Stderr,
+				"error: failed to read compliance mapping. %s\n", err)
+			return ERR_INVALIDOPT
+		}
+	}
+
+	// add user catalogue of tests
+	if len(catalogueOpt) > 0 {
+		if err := readCatalogue(catalogueOpt); err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read catalogue. %s\n",
+				err)
+			return ERR_INVALIDOPT
+		}
+	}
+
+	// add user severity model
+	if len(severityOpt) > 0 {
+		if err := readSeverityModel(severityOpt); err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read severity model. %s\n",
+				err)
+			return ERR_INVALIDOPT
+		}
+	}
+
+	// set filters for findings
+	include, err := parseFilters(includeOpt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+	exclude, err := parseFilters(excludeOpt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	// read suppressions
+	var suppressions *lynis.Suppressions
+	if len(suppressOpt) > 0 {
+		suppressions, err = readSuppressions(suppressOpt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"error: failed to read suppressions file %s. %s\n",
+				suppressOpt, err)
+			return ERR_INVALIDOPT
+		}
+	}
+
+	// create report before reading it
+	if produce != nil {
+		if code := produce(); code != 0 {
+			return code
+		}
 	}
 
 	// open report file
@@ -124,42 +358,156 @@ func main() {
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: %s\n",
 				err)
-			os.Exit(ERR_REPORTFILE)
+			return ERR_REPORTFILE
 		}
 	}
 
-	// open log file
-	var output *os.File
-	if len(logOpt) < 1 {
-		output = os.Stdout
-	} else {
-		var err error
-		output, err = os.OpenFile(logOpt,
-			os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	// Process Lynis report
+	report, err := lynis.CreateReport(input)
+	if err != nil {
+		//TODO log error message to log file
+		fmt.Fprintf(os.Stderr,
+			"error: failed to parse Lynis Report %s\n", err)
+		return ERR_PROCCESS
+	}
+
+	// Add execution of tests from Lynis log
+	if len(logInputOpt) > 0 {
+		lynisLog, err := lynis.ReadLogFile(logInputOpt)
 		if err != nil {
 			fmt.Fprintf(os.Stderr,
-				"error: failed to open log file %s\n",
-				logOpt)
-			os.Exit(ERR_LOGFILE)
+				"error: failed to read Lynis log %s. %s\n", logInputOpt, err)
+			return ERR_LOGINPUT
 		}
+		report.AddLog(lynisLog)
 	}
 
-	// Process Lynis report
-	_, data, err := lynis.Process(input, formatter)
+	// Store unmodified report in history
+	code := 0
+	if len(historyOpt) > 0 {
+		if err := saveHistory(report); err != nil {
+			fmt.Fprintf(os.Stderr,
+				"error: failed to store report in history. %s\n", err)
+			code = ERR_HISTORY
+		}
+	}
+
+	// Suppress accepted risks
+	if suppressions != nil {
+		var diagnostics []string
+		report, diagnostics = suppressions.Apply(report, time.Now(),
+			markSuppressOpt)
+		for _, d := range diagnostics {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", d)
+		}
+	}
+
+	// Check report complies with policy, findings that are not output are
+	// still checked
+	var violations []*lynis.Violation
+	if policyOpt.Enabled() {
+		violations = policyOpt.Evaluate(report)
+	}
+
+	// Remove filtered findings
+	if len(include) > 0 || len(exclude) > 0 {
+		report = report.Filter(lynis.IncludeExclude(include, exclude))
+	}
+
+	// Write report to all sinks
+	if sinkCode := writeSinks(report, sinks); sinkCode != 0 {
+		code = sinkCode
+	}
+
+	// Report policy violations
+	for _, v := range violations {
+		fmt.Fprintf(os.Stderr, "policy violation: %s: %s\n",
+			v.Rule, v.Message)
+	}
+	if len(violations) > 0 && code == 0 {
+		code = ERR_POLICY
+	}
+	return code
+}
+
+// Creates filters from filter expressions
+func parseFilters(exprs []string) ([]lynis.Filter, error) {
+	filters := make([]lynis.Filter, 0, len(exprs))
+	for _, expr := range exprs {
+		filter, err := lynis.ParseFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// Reads suppressions from file
+func readSuppressions(path string) (*lynis.Suppressions, error) {
+	input, err := os.Open(path)
 	if err != nil {
-		//TODO log error message to log file
-		fmt.Fprintf(os.Stderr,
-			"error: failed to parse Lynis Report %s\n", err)
-		os.Exit(ERR_PROCCESS)
+		return nil, err
 	}
+	defer input.Close()
 
-	// Write serialized data to output
-	if bytes, err := output.Write(data); err != nil {
-		fmt.Fprintf(os.Stderr,
-			"error: failed writting report to log file. Wrote %d bytes expected %d. %s\n",
-			bytes, len(data), err.Error())
-		os.Exit(ERR_WRITELOG)
+	return lynis.LoadSuppressions(input)
+}
+
+// Reads a severity model file and merges it into the built in model
+func readSeverityModel(path string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	model, err := lynis.LoadSeverityModel(input)
+	if err != nil {
+		return err
+	}
+	merged := lynis.DefaultSeverityModel()
+	merged.Merge(model)
+	lynis.SetSeverityModel(merged)
+	return nil
+}
+
+// Creates the sink declarations from the single output options, Loki is
+// pushed to along with the output format when both are given
+func legacySinks() []string {
+	var spec string
+	if fmtYamlOpt {
+		spec = "yaml"
+	} else if fmtElasticOpt {
+		spec = "elastic"
+	} else if fmtPromOpt {
+		spec = "prometheus"
+	} else if fmtInfluxOpt {
+		spec = "influx"
+	} else if fmtOCSFOpt {
+		spec = "ocsf"
+	} else if fmtTextOpt {
+		spec = "text"
+	} else if len(lokiOpt) > 0 && len(logOpt) < 1 {
+		// Loki push body must be left as is
+		return []string{"loki=" + lokiOpt}
+	} else {
+		spec = "json"
+	}
+
+	if fmtTimestampOpt {
+		spec += ",timestamp"
+	}
+	if fmtNewLineOpt {
+		spec += ",newline"
+	}
+	if len(logOpt) > 0 {
+		spec += "=" + logOpt
+	}
+	if len(lokiOpt) > 0 {
+		return []string{spec, "loki=" + lokiOpt}
 	}
+	return []string{spec}
 }
 
 func printHelp() {
@@ -172,7 +520,31 @@ func printHelp() {
         fmt.Fprintln(os.Stderr,"Usage:")
         fmt.Fprintln(os.Stderr,"")
         fmt.Fprintln(os.Stderr,"\tlynisreport [option]")
+	fmt.Fprintln(os.Stderr, "\tlynisreport exporter [option]")
+	fmt.Fprintln(os.Stderr, "\tlynisreport diff [option] OLD NEW")
+	fmt.Fprintln(os.Stderr, "\tlynisreport history [option]")
+	fmt.Fprintln(os.Stderr, "\tlynisreport trend [option] [FILE|DIR...]")
+	fmt.Fprintln(os.Stderr, "\tlynisreport evaluate [option] --rules FILE")
+	fmt.Fprintln(os.Stderr, "\tlynisreport compliance [option] --framework FRAMEWORK")
+	fmt.Fprintln(os.Stderr, "\tlynisreport catalogue [option]")
+	fmt.Fprintln(os.Stderr, "\tlynisreport inventory [option] [FILE|DIR...]")
+	fmt.Fprintln(os.Stderr, "\tlynisreport packages [option]")
+	fmt.Fprintln(os.Stderr, "\tlynisreport run [option]")
         fmt.Fprintln(os.Stderr,"")
         fmt.Fprintln(os.Stderr,"Options:")
         flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Exit codes:")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintf(os.Stderr, "\t%d\treport file could not be opened\n", ERR_REPORTFILE)
+	fmt.Fprintf(os.Stderr, "\t%d\tlog file could not be opened\n", ERR_LOGFILE)
+	fmt.Fprintf(os.Stderr, "\t%d\treport could not be parsed\n", ERR_PROCCESS)
+	fmt.Fprintf(os.Stderr, "\t%d\toutput could not be written\n", ERR_WRITELOG)
+	fmt.Fprintf(os.Stderr, "\t%d\tinvalid option\n", ERR_INVALIDOPT)
+	fmt.Fprintf(os.Stderr, "\t%d\toutput could not be pushed to Loki\n", ERR_PUSH)
+	fmt.Fprintf(os.Stderr, "\t%d\texporter could not listen\n", ERR_LISTEN)
+	fmt.Fprintf(os.Stderr, "\t%d\thistory could not be read or written\n", ERR_HISTORY)
+	fmt.Fprintf(os.Stderr, "\t%d\treport violates policy\n", ERR_POLICY)
+	fmt.Fprintf(os.Stderr, "\t%d\tLynis log file could not be read\n", ERR_LOGINPUT)
+	fmt.Fprintf(os.Stderr, "\t%d\tLynis could not be run\n", ERR_LYNIS)
 }
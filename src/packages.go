@@ -0,0 +1,116 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"io"
+	"lynisreport/lynis"
+	"os"
+)
+
+// Runs the packages command which outputs the installed and vulnerable
+// packages of a report, returns exit code
+func runPackages(args []string) int {
+	var help bool
+	var reportFile string
+	var format string
+	var output string
+
+	flags := flag.NewFlagSet("packages", flag.ContinueOnError)
+	flags.BoolVarP(&help,
+		"help",
+		"h",
+		false,
+		"Print help menu")
+	flags.StringVarP(&reportFile,
+		"reportfile",
+		"r",
+		"",
+		"Location of Lynis report file. Default is to read from stdin")
+	flags.StringVarP(&format,
+		"format",
+		"f",
+		"text",
+		"Output format, one of text, json or cyclonedx")
+	flags.StringVarP(&output,
+		"output",
+		"o",
+		"",
+		"File to write packages to. Default is to standard output")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if help {
+		fmt.Fprintln(os.Stderr, "Outputs the installed packages of a Lynis report and the packages Lynis found")
+		fmt.Fprintln(os.Stderr, "to be vulnerable. The cyclonedx format is a CycloneDX software bill of")
+		fmt.Fprintln(os.Stderr, "materials of the installed packages.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport packages [option]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+
+	var serialize func(*lynis.PackageReport, io.Writer) error
+	switch format {
+	case "text":
+		serialize = (*lynis.PackageReport).SerializeForText
+	case "json":
+		serialize = (*lynis.PackageReport).SerializeForJSON
+	case "cyclonedx":
+		serialize = (*lynis.PackageReport).SerializeForCycloneDX
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown format %s\n", format)
+		return ERR_INVALIDOPT
+	}
+
+	// read report
+	input := os.Stdin
+	if len(reportFile) > 0 {
+		var err error
+		input, err = os.Open(reportFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return ERR_REPORTFILE
+		}
+		defer input.Close()
+	}
+	report, err := lynis.CreateReport(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed processing report. %s\n", err)
+		return ERR_PROCCESS
+	}
+	packages := report.Packages()
+
+	if len(output) < 1 {
+		err = serialize(packages, os.Stdout)
+	} else {
+		var pending *lynis.PendingFile
+		pending, err = lynis.CreatePendingFile(output, false, 0644)
+		if err == nil {
+			err = serialize(packages, pending)
+			if err == nil {
+				err = pending.Commit()
+			} else {
+				pending.Abort()
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed writting packages. %s\n", err)
+		return ERR_WRITELOG
+	}
+	return 0
+}
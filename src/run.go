@@ -0,0 +1,170 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Locations Lynis is searched for when it is not in PATH
+var lynisPaths = []string{
+	"/usr/sbin/lynis",
+	"/usr/bin/lynis",
+	"/usr/local/bin/lynis",
+	"/usr/local/lynis/lynis",
+	"/opt/lynis/lynis",
+}
+
+// Runs the run command which runs a Lynis system audit and then processes the
+// report it created, returns exit code
+func runRun(args []string) int {
+	var lynisBin string
+	var profile string
+	var cronjob bool
+	var quick bool
+	var output string
+
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.StringVar(&lynisBin,
+		"lynis",
+		"",
+		"Location of Lynis. Default is to search PATH and common install locations")
+	flags.StringVar(&profile,
+		"profile",
+		"",
+		"Profile for Lynis to use during the audit")
+	flags.BoolVar(&cronjob,
+		"cronjob",
+		false,
+		"Run Lynis as a cron job which implies --quick and no colors")
+	flags.BoolVar(&quick,
+		"quick",
+		false,
+		"Run Lynis without waiting for user input")
+	flags.StringVar(&output,
+		"lynis-output",
+		"",
+		"File to write the output of Lynis to. Default is to only print it when Lynis fails")
+	addOptions(flags)
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if helpOpt {
+		fmt.Fprintln(os.Stderr, "Runs a Lynis system audit writing the report to the report file and then")
+		fmt.Fprintln(os.Stderr, "processes the report with the same options as lynisreport. The Lynis log is")
+		fmt.Fprintln(os.Stderr, "written to and read from the file given with --logfile-input.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport run [option]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+
+	if len(repOpt) < 1 {
+		fmt.Fprintf(os.Stderr, "error: run requires a report file for Lynis to write\n")
+		return ERR_INVALIDOPT
+	}
+
+	if len(lynisBin) < 1 {
+		var err error
+		lynisBin, err = findLynis()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return ERR_LYNIS
+		}
+	}
+
+	// arguments of the Lynis audit
+	lynisArgs := []string{"audit", "system", "--no-colors",
+		"--report-file", repOpt}
+	if len(logInputOpt) > 0 {
+		lynisArgs = append(lynisArgs, "--log-file", logInputOpt)
+	}
+	if len(profile) > 0 {
+		lynisArgs = append(lynisArgs, "--profile", profile)
+	}
+	if cronjob {
+		lynisArgs = append(lynisArgs, "--cronjob")
+	}
+	if quick {
+		lynisArgs = append(lynisArgs, "--quick")
+	}
+
+	return processReport(func() int {
+		return runLynis(lynisBin, lynisArgs, output)
+	})
+}
+
+// Returns the location of Lynis found in PATH or common install locations
+func findLynis() (string, error) {
+	if path, err := exec.LookPath("lynis"); err == nil {
+		return path, nil
+	}
+	for _, path := range lynisPaths {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", errors.New("failed to find Lynis, use --lynis to set its location")
+}
+
+// Runs Lynis and checks it wrote the report, returns exit code. The output of
+// Lynis is written to the output file if it is set and printed when Lynis
+// fails
+func runLynis(lynisBin string, args []string, output string) int {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(lynisBin, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now().Truncate(time.Second)
+	runErr := cmd.Run()
+
+	if len(output) > 0 {
+		data := append(stdout.Bytes(), stderr.Bytes()...)
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr,
+				"warning: failed writting Lynis output to %s. %s\n",
+				output, err)
+		}
+	}
+
+	// a report written by this run can still be processed if Lynis failed
+	info, statErr := os.Stat(repOpt)
+	written := statErr == nil && !info.ModTime().Before(start)
+	if runErr == nil && written {
+		return 0
+	}
+
+	if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	if runErr != nil {
+		if !written {
+			fmt.Fprintf(os.Stderr, "error: failed running Lynis. %s\n",
+				runErr)
+			return ERR_LYNIS
+		}
+		fmt.Fprintf(os.Stderr, "warning: Lynis failed. %s\n", runErr)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "error: Lynis did not write report %s\n", repOpt)
+	return ERR_LYNIS
+}
@@ -0,0 +1,179 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"lynisreport/lynis"
+	"os"
+	"strings"
+)
+
+// Sink is a destination that a report is written to with its own chain of
+// formatters. Sinks are declared on the command line as
+//
+//	FORMAT[,MODIFIER...][=DESTINATION]
+//
+// where FORMAT is one of json, elastic, prometheus, influx, ocsf, loki or
+// text, MODIFIER is timestamp or newline and DESTINATION is a file path, - for
+// standard output or the server URL for loki. Without a destination output is
+// written to standard output
+type Sink struct {
+	Spec        string                // sink declaration
+	Formatter   lynis.OutputFormatter // head of formatter chain
+	Destination string                // file path, URL or - for stdout
+	replace     bool                  // replace file instead of appending
+	loki        bool                  // push output to Loki
+}
+
+// Error from writing to a sink along with the exit code for it
+type sinkError struct {
+	code int
+	err  error
+}
+
+func (se *sinkError) Error() string {
+	return se.err.Error()
+}
+
+// Creates a formatter for the format name
+func newFormatter(format string) (lynis.OutputFormatter, error) {
+	switch format {
+	case "json":
+		return &lynis.FormatJSON{}, nil
+	case "elastic":
+		return &lynis.FormatElasticJSON{}, nil
+	case "prometheus":
+		return &lynis.FormatPrometheus{}, nil
+	case "influx":
+		return &lynis.FormatInflux{}, nil
+	case "ocsf":
+		return &lynis.FormatOCSF{}, nil
+	case "loki":
+		return &lynis.FormatLoki{}, nil
+	case "text":
+		return &lynis.FormatText{}, nil
+	case "yaml":
+		return nil, errors.New("yaml output is not yet implemented")
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown format %s", format))
+	}
+}
+
+// Creates a sink from its declaration
+func parseSink(spec string) (*Sink, error) {
+	chain, dest, _ := strings.Cut(spec, "=")
+	names := strings.Split(chain, ",")
+
+	format := strings.TrimSpace(names[0])
+	formatter, err := newFormatter(format)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &Sink{
+		Spec:        spec,
+		Formatter:   formatter,
+		Destination: strings.TrimSpace(dest),
+		replace:     format == "prometheus",
+		loki:        format == "loki",
+	}
+	if sink.loki && len(sink.Destination) < 1 {
+		return nil, errors.New("loki sink requires the server URL")
+	}
+
+	// add modifiers to chain
+	for _, name := range names[1:] {
+		switch strings.TrimSpace(name) {
+		case "timestamp":
+			// timestamp is written before data
+			timestamp := &lynis.FormatTimestamp{}
+			timestamp.SetNext(sink.Formatter)
+			sink.Formatter = timestamp
+		case "newline":
+			lynis.SetNext(sink.Formatter, &lynis.FormatNewLine{})
+		default:
+			return nil, errors.New(fmt.Sprintf("unknown modifier %s", name))
+		}
+	}
+
+	return sink, nil
+}
+
+// Writes the report to the sink. Files and standard output are only written
+// to once all output has been formatted successfully
+func (s *Sink) Write(report *lynis.Report, stdout io.Writer) error {
+	// push output to Loki once it is complete
+	if s.loki {
+		data := &bytes.Buffer{}
+		if err := lynis.Write(report, data, s.Formatter); err != nil {
+			return &sinkError{ERR_WRITELOG, err}
+		}
+		client := &lynis.LokiClient{URL: s.Destination, Tenant: lokiTenantOpt}
+		if err := client.Push(data.Bytes()); err != nil {
+			return &sinkError{ERR_PUSH, err}
+		}
+		return nil
+	}
+
+	// standard output is written to once output is complete
+	if len(s.Destination) < 1 || s.Destination == "-" {
+		data := &bytes.Buffer{}
+		if err := lynis.Write(report, data, s.Formatter); err != nil {
+			return &sinkError{ERR_WRITELOG, err}
+		}
+		if _, err := stdout.Write(data.Bytes()); err != nil {
+			return &sinkError{ERR_WRITELOG, err}
+		}
+		return nil
+	}
+
+	// metrics files are replaced instead of appended to
+	var pending *lynis.PendingFile
+	var err error
+	if s.replace {
+		pending, err = lynis.CreatePendingFile(s.Destination, false, 0644)
+	} else {
+		pending, err = lynis.CreatePendingFile(s.Destination, true, 0640)
+	}
+	if err != nil {
+		return &sinkError{ERR_LOGFILE, err}
+	}
+
+	err = lynis.Write(report, pending, s.Formatter)
+	if err == nil {
+		err = pending.Commit()
+	}
+	if err != nil {
+		pending.Abort()
+		return &sinkError{ERR_WRITELOG, err}
+	}
+	return nil
+}
+
+// Writes the report to every sink, a failure in one sink does not stop the
+// report being written to the others. Errors are printed to stderr and the
+// exit code of the first failed sink is returned
+func writeSinks(report *lynis.Report, sinks []*Sink) int {
+	code := 0
+	for _, s := range sinks {
+		err := s.Write(report, os.Stdout)
+		if err == nil {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "error: failed writting report to sink %s. %s\n",
+			s.Spec, err)
+		if se, ok := err.(*sinkError); ok && code == 0 {
+			code = se.code
+		}
+	}
+	return code
+}
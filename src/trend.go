@@ -0,0 +1,202 @@
+package main
+
+/*
+* Author: Matt MacKay
+*  Email: mmacKay055@gmail.com
+*   Date: 2022-04-06
+ */
+
+import (
+	"errors"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"lynisreport/lynis"
+	"os"
+	"path/filepath"
+)
+
+// Runs the trend command which analyzes a series of reports, returns exit
+// code
+func runTrend(args []string) int {
+	var help bool
+	var historyDir string
+	var host string
+	var format string
+	var tests bool
+
+	flags := flag.NewFlagSet("trend", flag.ContinueOnError)
+	flags.BoolVarP(&help,
+		"help",
+		"h",
+		false,
+		"Print help menu")
+	flags.StringVar(&historyDir,
+		"history",
+		"",
+		"Read reports of host from history directory instead of report files")
+	flags.StringVar(&host,
+		"host",
+		"",
+		"Host ID to read reports of from history or report files")
+	flags.StringVarP(&format,
+		"format",
+		"f",
+		"text",
+		"Output format, one of text, csv or json")
+	flags.BoolVar(&tests,
+		"tests",
+		false,
+		"Output tests instead of reports in csv format")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return ERR_INVALIDOPT
+	}
+
+	if help {
+		fmt.Fprintln(os.Stderr, "Analyzes a series of reports of a host showing warnings, suggestions and")
+		fmt.Fprintln(os.Stderr, "hardening index over time, mean time to remediate each test and findings")
+		fmt.Fprintln(os.Stderr, "that are flapping. Reports are read from files, directories of report files")
+		fmt.Fprintln(os.Stderr, "or the history.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "\tlynisreport trend [option] [FILE|DIR...]")
+		fmt.Fprintln(os.Stderr, "\tlynisreport trend [option] --history DIR --host HOST")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		flags.PrintDefaults()
+		return 0
+	}
+
+	var reports []*lynis.Report
+	var err error
+	if len(historyDir) > 0 {
+		if len(host) < 1 {
+			fmt.Fprintf(os.Stderr, "error: --host is required with --history\n")
+			return ERR_INVALIDOPT
+		}
+		reports, err = historyReports(&lynis.History{Dir: historyDir}, host)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to read history. %s\n", err)
+			return ERR_HISTORY
+		}
+	} else {
+		if flags.NArg() < 1 {
+			fmt.Fprintf(os.Stderr, "error: trend requires report files or --history\n")
+			return ERR_INVALIDOPT
+		}
+		reports, err = readReports(flags.Args())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return ERR_REPORTFILE
+		}
+		if len(host) > 0 {
+			reports = hostReports(reports, host)
+		}
+	}
+
+	// reports without a scan time can not be placed in the series
+	scanned := make([]*lynis.Report, 0, len(reports))
+	for _, report := range reports {
+		if _, err := lynis.ScanTime(report); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping report of %s. %s\n",
+				lynis.HistoryHost(report), err)
+			continue
+		}
+		scanned = append(scanned, report)
+	}
+
+	trend, err := lynis.Analyze(scanned)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s, use --host to select one\n", err)
+		return ERR_INVALIDOPT
+	}
+	switch format {
+	case "text":
+		err = trend.SerializeForText(os.Stdout)
+	case "csv":
+		err = trend.SerializeForCSV(os.Stdout, tests)
+	case "json":
+		err = trend.SerializeForJSON(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown format %s\n", format)
+		return ERR_INVALIDOPT
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed writting trend. %s\n", err)
+		return ERR_WRITELOG
+	}
+	return 0
+}
+
+// Loads every stored report of the host
+func historyReports(history *lynis.History, host string) ([]*lynis.Report, error) {
+	runs, err := history.Runs(host)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*lynis.Report, 0, len(runs))
+	for _, run := range runs {
+		report, err := history.Load(run)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// Returns the reports of the host, the host is the host ID or hostname
+func hostReports(reports []*lynis.Report, host string) []*lynis.Report {
+	selected := make([]*lynis.Report, 0)
+	for _, report := range reports {
+		if lynis.HistoryHost(report) == host || report.HostID == host ||
+			report.Hostname == host {
+			selected = append(selected, report)
+		}
+	}
+	return selected
+}
+
+// Reads report files, directories are read for report files. Files in
+// directories that are not valid reports are skipped with a warning
+func readReports(paths []string) ([]*lynis.Report, error) {
+	reports := make([]*lynis.Report, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			report, err := lynis.ReadReportFile(path)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf(
+					"failed to read report %s. %s", path, err))
+			}
+			reports = append(reports, report)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			file := filepath.Join(path, e.Name())
+			report, err := lynis.ReadReportFile(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: skipping %s. %s\n",
+					file, err)
+				continue
+			}
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}